@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"test/eventlog"
+)
+
+func runAnalyzeEvents(paths []string) error {
+	var events []eventlog.Event
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		read, skipped, err := eventlog.Read(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if skipped > 0 {
+			log.Printf("%s: skipped %d unreadable lines", path, skipped)
+		}
+		events = append(events, read...)
+	}
+	return eventlog.WriteReport(os.Stdout, eventlog.AnalyzeLog(events))
+}
@@ -0,0 +1,91 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"test/config"
+	"test/layouts"
+	"test/queries"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// batchCount returns how many batches of size documents it takes to write
+// total documents.
+func batchCount(total, size int) int {
+	return (total + size - 1) / size
+}
+
+// batchLen returns the number of documents in batch i of a write of total
+// documents; only the last batch can be short.
+func batchLen(total, size, i int) int {
+	if rest := total - i*size; rest < size {
+		return rest
+	}
+	return size
+}
+
+// writeBatch writes the next n documents of docs with layout in a single
+// InsertMany or BulkWrite call and returns how many of them were inserted.
+// In bulkWrite mode batch.UpdateRatio of the operations update the document
+// of a device written before instead of inserting; writeBatch also returns
+// how many documents those updates matched.
+func writeBatch(ctx context.Context, layout layouts.Layout, collection *mongo.Collection, docs *queries.Documents, n int, batch config.Batch) (int, int, error) {
+	repo := queries.NewAdvertisementHistoryRepo[interface{}](collection)
+	if batch.Mode == config.BatchBulkWrite {
+		models := make([]mongo.WriteModel, 0, n)
+		// devices holds the deviceId of every model, 0 for the updates.
+		devices := make([]int64, 0, n)
+		due := 0.0
+		for k := 0; k < n; k++ {
+			due += batch.UpdateRatio
+			if due >= 1 {
+				if device := docs.NextUpdateDevice(); device > 0 {
+					due--
+					models = append(models, queries.NewAudioPlayedUpdate(device))
+					devices = append(devices, 0)
+					continue
+				}
+			}
+			doc := layout.NewDocument(docs)
+			models = append(models, mongo.NewInsertOneModel().SetDocument(doc))
+			devices = append(devices, doc.(interface{ Device() int64 }).Device())
+		}
+		result, err := repo.BulkWrite(ctx, models, batch.Ordered)
+		docs.AddWritten(writtenDevices(devices, batch.Ordered, err)...)
+		return int(result.InsertedCount), int(result.MatchedCount), err
+	}
+
+	documents := make([]interface{}, n)
+	for k := range documents {
+		documents[k] = layout.NewDocument(docs)
+	}
+	inserted, err := repo.InsertMany(ctx, documents, batch.Ordered)
+	return inserted, 0, err
+}
+
+// writtenDevices returns the deviceIds of the inserts a bulk write that
+// returned err wrote, out of devices, the deviceId of each of its models or
+// 0 for the updates.
+func writtenDevices(devices []int64, ordered bool, err error) []int64 {
+	var bulkErr mongo.BulkWriteException
+	failed := make(map[int]bool)
+	switch {
+	case err == nil:
+	case !errors.As(err, &bulkErr) || len(bulkErr.WriteErrors) == 0:
+		return nil
+	case ordered:
+		devices = devices[:bulkErr.WriteErrors[0].Index]
+	default:
+		for _, e := range bulkErr.WriteErrors {
+			failed[e.Index] = true
+		}
+	}
+	written := make([]int64, 0, len(devices))
+	for i, device := range devices {
+		if device != 0 && !failed[i] {
+			written = append(written, device)
+		}
+	}
+	return written
+}
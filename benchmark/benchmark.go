@@ -0,0 +1,218 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"test/config"
+	"test/datagen"
+	"test/layouts"
+	"test/monitor"
+	"test/queries"
+	"test/results"
+	"test/stats"
+	"test/tracing"
+	"test/workload"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Run drives the layout named by cfg.Layout through the phases of cfg and
+// returns their results.
+func Run(cfg config.Config) *results.Result {
+	layout, ok := layouts.Get(cfg.Layout)
+	if !ok {
+		log.Fatalf("Unknown layout %q", cfg.Layout)
+	}
+	log.Printf("------ %s: %s ------", layout.Name(), layout.Describe())
+
+	uri := cfg.URI
+	fmt.Println(uri)
+
+	runID := primitive.NewObjectID().Hex()
+	mon := monitor.New()
+	if cfg.Options.EventsLog != "" {
+		closeEvents, err := mon.LogEvents(cfg.Options.EventsLog, runID)
+		if err != nil {
+			log.Fatalf("Failed to open events log: %v", err)
+		}
+		defer closeEvents()
+	}
+	if cfg.Options.MetricsAddr != "" {
+		stopMetrics := serveMetrics(cfg.Options.MetricsAddr, mon.MetricsHandler(layout.Name()))
+		defer stopMetrics()
+	}
+	var tracer *tracing.Tracer
+	if cfg.Tracing.Enabled() {
+		var err error
+		tracer, err = tracing.New(context.TODO(), cfg.Tracing, attribute.String("mongobench.layout", layout.Name()), attribute.String("mongobench.run_id", runID))
+		if err != nil {
+			log.Fatalf("Failed to start tracing: %v", err)
+		}
+		defer func() {
+			if err := tracer.Close(context.TODO()); err != nil {
+				log.Printf("Failed to close tracing: %v", err)
+			}
+		}()
+	}
+	clientOpts := options.Client().ApplyURI(uri).
+		SetServerMonitor(mon.ServerMonitor()).
+		SetMonitor(tracer.CommandMonitor(mon.CommandMonitor())).
+		SetPoolMonitor(mon.PoolMonitor())
+
+	client, err := mongo.Connect(context.TODO(), clientOpts)
+	if err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+	defer func() {
+		if err = client.Disconnect(context.TODO()); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	DB := client.Database(cfg.Database)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = tracing.NewContext(ctx, tracer)
+
+	if cfg.Options.DropCollection {
+		if err := layout.Cleanup(ctx, DB.Collection(cfg.Collection)); err != nil {
+			log.Fatalf("Failed to drop collection: %v", err)
+		}
+	}
+	advertisementHistory, err := layout.Create(ctx, DB, cfg.Collection)
+	if err != nil {
+		log.Fatalf("Failed to create collection: %v", err)
+	}
+
+	if cfg.Options.CreateIndexes {
+		queries.CreateIndex(advertisementHistory, ctx)
+	}
+	// Without the index every page keyed on deviceId would be sorted in
+	// memory, which is not what the read phases are meant to measure.
+	if cfg.Read.PageSize > 0 && (cfg.HasPhase(config.PhaseRead) || cfg.HasPhase(config.PhaseReadSorted)) {
+		name, err := queries.NewAdvertisementHistoryRepo[bson.M](advertisementHistory).CreatePageIndex(ctx, cfg.Read.PageKey)
+		if err != nil {
+			log.Fatalf("Failed to create the index of pages keyed on %s: %v", cfg.Read.PageKey, err)
+		}
+		if name != "" {
+			log.Printf("Created index %s for pages keyed on %s", name, cfg.Read.PageKey)
+		}
+	}
+	docs := queries.NewDocuments(datagen.New(cfg.Data), cfg.ClusterKey)
+	log.Printf("Generating documents with seed %d", docs.Seed())
+
+	writers, closeWriters, err := workload.Collections(ctx, client, clientOpts, cfg.Database, cfg.Collection, cfg.Workers, cfg.WorkerAffinity)
+	if err != nil {
+		log.Fatalf("Failed to connect writers: %v", err)
+	}
+	defer closeWriters()
+
+	phases := stats.NewPhases()
+	startedAt := time.Now()
+	sampling, stopSampling := context.WithCancel(ctx)
+	go mon.SamplePools(sampling, time.Second)
+	var statuses *serverStatusSampler
+	if cfg.ServerStatusInterval > 0 {
+		statuses = newServerStatusSampler(ctx, client, uri, mon, time.Duration(cfg.ServerStatusInterval))
+		defer statuses.close()
+		go statuses.run(sampling)
+	}
+	prog := newProgress()
+	if cfg.Options.Live {
+		go prog.dashboard(sampling, os.Stdout, layout.Name(), mon)
+	}
+
+	var watcher *failover
+	stopWatching := func() {}
+	if cfg.Failover.Enabled {
+		watcher = newFailover(ctx, client, mon, cfg.Failover)
+		var watching context.Context
+		watching, stopWatching = context.WithCancel(ctx)
+		go watcher.watch(watching)
+	}
+
+	if cfg.HasPhase(config.PhaseWrite) {
+		writePhase(ctx, cfg, layout, writers, docs, phases, mon, statuses, watcher, prog)
+	}
+
+	var reads []results.Read
+	if cfg.HasPhase(config.PhaseRead) {
+		log.Printf("------ Mongo Unordered Read ------")
+		reads = append(reads, readPhase(ctx, cfg, layout, advertisementHistory, config.PhaseRead, phases))
+	}
+
+	if cfg.HasPhase(config.PhaseReadSorted) {
+		log.Printf("------ Mongo Ordered Read ------")
+		reads = append(reads, readPhase(ctx, cfg, layout, advertisementHistory, config.PhaseReadSorted, phases))
+	}
+
+	stopWatching()
+	stopSampling()
+	mon.Log()
+
+	return report(cfg, advertisementHistory, ctx, phases, runID, docs.Seed(), startedAt, mon, watcher, statuses, reads)
+}
+
+func report(cfg config.Config, collection *mongo.Collection, ctx context.Context, phases *stats.Phases, runID string, seed int64, startedAt time.Time, mon *monitor.Monitor, watcher *failover, statuses *serverStatusSampler, reads []results.Read) *results.Result {
+	// Nothing here ends the run: whatever fails is logged and left out of
+	// the results, which are saved anyway.
+	repo := queries.NewAdvertisementHistoryRepo[bson.M](collection)
+	if indexes, err := repo.ListIndexes(ctx); err != nil {
+		log.Printf("Failed to list indexes: %v", err)
+	} else {
+		log.Printf("Indexes %v", indexes)
+	}
+	// queries.GetIndexStatus(collection, ctx)
+	// queries.GetServerStatus(db, ctx)
+	if cfg.Options.Explain {
+		startExplain := time.Now()
+		explain, err := repo.Explain(ctx, queries.Filter{}, queries.ExplainOptions)
+		phases.Get("explain").Since(startExplain)
+		if err != nil {
+			log.Printf("Failed to run explain: %v", err)
+		} else {
+			log.Printf("Explain result: %+v", explain)
+		}
+	}
+	phases.Log(cfg.Layout)
+
+	result := results.New(cfg, startedAt, phases)
+	result.RunID = runID
+	result.Seed = seed
+	if collStats, err := repo.Stats(ctx); err != nil {
+		log.Printf("Failed to get collection stats: %v", err)
+	} else {
+		result.Documents = collStats.Count
+		result.DataSize = collStats.Size
+		result.StorageSize = collStats.StorageSize
+		result.IndexSize = collStats.TotalIndexSize
+	}
+
+	result.AddMonitor(mon)
+	result.Reads = reads
+	if watcher != nil {
+		result.Failover = watcher.report()
+	}
+	if statuses != nil {
+		result.ServerStatus = statuses.report()
+	}
+
+	if cfg.Options.ResultsDir == "" {
+		return result
+	}
+	path, err := result.Save(cfg.Options.ResultsDir)
+	if err != nil {
+		log.Printf("Failed to save results: %v", err)
+		return result
+	}
+	log.Printf("Results written to %s", path)
+	return result
+}
@@ -0,0 +1,116 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"test/monitor"
+	"test/stats"
+	"text/tabwriter"
+	"time"
+)
+
+// progress tracks the writes of the stage that is running, for the live
+// dashboard.
+type progress struct {
+	mu      sync.Mutex
+	phase   string
+	total   int64
+	until   time.Time
+	started time.Time
+	done    int64
+	// window holds the latencies since the dashboard last redrew.
+	window        *stats.Histogram
+	windowStarted time.Time
+}
+
+func newProgress() *progress {
+	now := time.Now()
+	return &progress{started: now, window: stats.NewHistogram(), windowStarted: now}
+}
+
+// start begins a stage of total operations, or lasting d when total is 0.
+func (p *progress) start(phase string, total int, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	p.phase, p.total, p.done, p.started = phase, int64(total), 0, now
+	p.until = time.Time{}
+	if d > 0 {
+		p.until = now.Add(d)
+	}
+}
+
+func (p *progress) record(d time.Duration) {
+	p.mu.Lock()
+	p.done++
+	window := p.window
+	p.mu.Unlock()
+	window.Record(d)
+}
+
+// dashboard redraws a live view of the run on out every second until ctx
+// is done.
+func (p *progress) dashboard(ctx context.Context, out io.Writer, layout string, mon *monitor.Monitor) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.draw(out, now, layout, mon)
+		}
+	}
+}
+
+func (p *progress) draw(out io.Writer, now time.Time, layout string, mon *monitor.Monitor) {
+	p.mu.Lock()
+	window, windowStarted := p.window, p.windowStarted
+	p.window, p.windowStarted = stats.NewHistogram(), now
+	phase, total, done, started, until := p.phase, p.total, p.done, p.started, p.until
+	p.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+	elapsed := now.Sub(started)
+	fmt.Fprintf(&b, "%s %s  %s elapsed\n", layout, phase, elapsed.Round(time.Second))
+	switch {
+	case total > 0:
+		eta := "-"
+		if done > 0 {
+			eta = time.Duration(float64(elapsed) * float64(total-done) / float64(done)).Round(time.Second).String()
+		}
+		fmt.Fprintf(&b, "progress  %d/%d (%.1f%%)  ETA %s\n", done, total, 100*float64(done)/float64(total), eta)
+	case !until.IsZero():
+		fmt.Fprintf(&b, "progress  %d ops  %s left\n", done, until.Sub(now).Round(time.Second))
+	}
+
+	summary := window.Summary()
+	fmt.Fprintf(&b, "ops/s     %.1f\n", float64(summary.Count)/now.Sub(windowStarted).Seconds())
+	fmt.Fprintf(&b, "latency   p50 %s  p90 %s  p99 %s  max %s\n", summary.P50, summary.P90, summary.P99, summary.Max)
+
+	var errors int64
+	byCode := map[string]int64{}
+	for _, f := range mon.Failures() {
+		errors += f.Count
+		byCode[f.Code] += f.Count
+	}
+	codes := make([]string, 0, len(byCode))
+	for code := range byCode {
+		codes = append(codes, fmt.Sprintf("%s %d", code, byCode[code]))
+	}
+	sort.Strings(codes)
+	fmt.Fprintf(&b, "errors    %d %s\n\n", errors, strings.Join(codes, ", "))
+
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "member\ttype\trtt\t")
+	for _, s := range mon.Servers() {
+		fmt.Fprintf(w, "%s\t%s\t%.3fms\t\n", s.Address, s.NewType, s.AvgRTTMs)
+	}
+	w.Flush()
+	io.WriteString(out, b.String())
+}
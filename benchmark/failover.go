@@ -0,0 +1,194 @@
+package benchmark
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"test/config"
+	"test/eventlog"
+	"test/layouts"
+	"test/monitor"
+	"test/queries"
+	"test/results"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// failover watches the replica set while the write phase runs and keeps
+// the outcome of every write, see config.Failover.
+type failover struct {
+	client *mongo.Client
+	mon    *monitor.Monitor
+	poll   time.Duration
+	stall  time.Duration
+
+	mu sync.Mutex
+	// before is the primary when the run started, or when watch first saw
+	// one if there was none then; lostAt and newAt are when
+	// replSetGetStatus first showed another or no primary and then a
+	// primary again, after.
+	before string
+	after  string
+	lostAt time.Time
+	newAt  time.Time
+
+	writes, failed, stalled, retried int64
+	firstFailed, lastFailed          time.Time
+	// unavailableFrom and unavailableTo span every failed or stalled write.
+	unavailableFrom, unavailableTo time.Time
+}
+
+func newFailover(ctx context.Context, client *mongo.Client, mon *monitor.Monitor, cfg config.Failover) *failover {
+	f := &failover{client: client, mon: mon, poll: time.Duration(cfg.Poll), stall: time.Duration(cfg.Stall)}
+	primary, _, err := getReplicaSetStatus(f.client, ctx)
+	if err != nil {
+		log.Printf("Failed to get replica set status: %v", err)
+	}
+	f.before = primary
+	if primary == "" {
+		log.Printf("Watching for failover, waiting for a primary")
+	} else {
+		log.Printf("Watching for failover, primary is %q", primary)
+	}
+	return f
+}
+
+// watch polls replSetGetStatus until ctx is done.
+func (f *failover) watch(ctx context.Context) {
+	ticker := time.NewTicker(f.poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		pollCtx, cancel := context.WithTimeout(ctx, 5*f.poll)
+		primary, _, err := getReplicaSetStatus(f.client, pollCtx)
+		cancel()
+		if err != nil {
+			continue
+		}
+		now := time.Now()
+
+		f.mu.Lock()
+		// Without a primary to start from there is nothing to lose yet.
+		if f.before == "" {
+			if primary != "" {
+				f.before = primary
+				log.Printf("Primary is %q", primary)
+			}
+			f.mu.Unlock()
+			continue
+		}
+		if f.lostAt.IsZero() && primary != f.before {
+			f.lostAt = now
+			log.Printf("Primary %q lost", f.before)
+		}
+		if !f.lostAt.IsZero() && f.newAt.IsZero() && primary != "" {
+			f.newAt, f.after = now, primary
+			log.Printf("New primary %q after %s", primary, now.Sub(f.lostAt))
+		}
+		f.mu.Unlock()
+	}
+}
+
+// write inserts the next document of docs with layout and records how it
+// went.
+func (f *failover) write(ctx context.Context, layout layouts.Layout, collection *mongo.Collection, docs *queries.Documents) error {
+	ctx, attempts := monitor.CountAttempts(ctx)
+	start := time.Now()
+	err := layout.Write(ctx, collection, docs)
+	end := time.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writes++
+	stalled := end.Sub(start) > f.stall
+	switch {
+	case err != nil:
+		if f.failed == 0 {
+			log.Printf("First failed write: %v", err)
+			f.firstFailed = start
+		}
+		f.failed++
+		f.lastFailed = end
+	case stalled:
+		f.stalled++
+	}
+	if err == nil && atomic.LoadInt32(attempts) > 1 {
+		f.retried++
+	}
+	if err != nil || stalled {
+		if f.unavailableFrom.IsZero() || start.Before(f.unavailableFrom) {
+			f.unavailableFrom = start
+		}
+		if end.After(f.unavailableTo) {
+			f.unavailableTo = end
+		}
+	}
+	return err
+}
+
+// report logs and returns what the run saw of the failover.
+func (f *failover) report() *results.Failover {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r := &results.Failover{
+		PrimaryBefore:       f.before,
+		PrimaryAfter:        f.after,
+		StatusPrimaryLostAt: f.lostAt,
+		StatusNewPrimaryAt:  f.newAt,
+		Writes:              f.writes,
+		FailedWrites:        f.failed,
+		StalledWrites:       f.stalled,
+		RetriedWrites:       f.retried,
+		FirstFailedWrite:    f.firstFailed,
+		LastFailedWrite:     f.lastFailed,
+		UnavailableSeconds:  f.unavailableTo.Sub(f.unavailableFrom).Seconds(),
+	}
+
+	// The driver usually notices before the next poll does. Changes without
+	// a previous primary are the initial discovery.
+	for _, t := range eventlog.Analyze(f.mon.ServerEvents()) {
+		for _, c := range t.PrimaryChanges {
+			switch {
+			case c.From == "":
+			case c.To == "" && r.PrimaryLostAt.IsZero():
+				r.PrimaryLostAt = c.Time
+			case c.To != "" && r.NewPrimaryAt.IsZero():
+				if r.PrimaryLostAt.IsZero() {
+					r.PrimaryLostAt = c.Time
+				}
+				r.NewPrimaryAt = c.Time
+				if r.PrimaryAfter == "" {
+					r.PrimaryAfter = c.To
+				}
+			}
+		}
+	}
+	switch {
+	case !r.NewPrimaryAt.IsZero():
+		r.ElectionSeconds = r.NewPrimaryAt.Sub(r.PrimaryLostAt).Seconds()
+	case !r.StatusNewPrimaryAt.IsZero():
+		r.ElectionSeconds = r.StatusNewPrimaryAt.Sub(r.StatusPrimaryLostAt).Seconds()
+	}
+
+	log.Printf("------ Failover ------")
+	log.Printf("Primary %q -> %q, election took %.3fs", r.PrimaryBefore, r.PrimaryAfter, r.ElectionSeconds)
+	log.Printf("Primary lost at %s, new primary at %s (replSetGetStatus: %s, %s)",
+		stamp(r.PrimaryLostAt), stamp(r.NewPrimaryAt), stamp(r.StatusPrimaryLostAt), stamp(r.StatusNewPrimaryAt))
+	log.Printf("%d writes: %d failed, %d stalled over %s, %d succeeded after a retry",
+		r.Writes, r.FailedWrites, r.StalledWrites, f.stall, r.RetriedWrites)
+	log.Printf("Writes failed or stalled for %.3fs", r.UnavailableSeconds)
+	return r
+}
+
+func stamp(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("15:04:05.000")
+}
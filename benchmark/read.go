@@ -0,0 +1,124 @@
+package benchmark
+
+import (
+	"context"
+	"log"
+	"runtime"
+	"runtime/metrics"
+	"test/config"
+	"test/layouts"
+	"test/monitor"
+	"test/queries"
+	"test/results"
+	"test/stats"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// readPhase streams the documents of collection as cfg.Read says, in _id
+// order for PhaseReadSorted, and records every page in phases. It returns
+// how fast the documents arrived and how much heap the client needed.
+func readPhase(ctx context.Context, cfg config.Config, layout layouts.Layout, collection *mongo.Collection, phase string, phases *stats.Phases) results.Read {
+	opts := queries.ReadOptions{
+		Filter:     queries.Filter{DeviceID: cfg.Read.DeviceID},
+		BatchSize:  cfg.Read.BatchSize,
+		PageSize:   cfg.Read.PageSize,
+		PageKey:    cfg.Read.PageKey,
+		Limit:      cfg.Read.Limit,
+		ClusterKey: cfg.ClusterKey,
+	}
+	switch {
+	case opts.PageSize > 0:
+		log.Printf("Reading in pages of %d keyed on %s, batches of %d", opts.PageSize, opts.PageKey, opts.BatchSize)
+	case opts.BatchSize > 0:
+		log.Printf("Reading in batches of %d", opts.BatchSize)
+	}
+
+	heap := sampleHeap()
+	read := layout.Read
+	if phase == config.PhaseReadSorted {
+		read = layout.ReadSorted
+	}
+	readCtx, done := monitor.Track(ctx)
+	readStats, err := read(readCtx, collection, opts)
+	done(err)
+	before, peak := heap.stop()
+	if err != nil {
+		log.Printf("Failed to read: %v", err)
+	}
+	phases.Get(phase).Merge(readStats.Pages)
+	phases.AddElapsed(phase, readStats.Elapsed)
+	phases.AddDocs(phase, readStats.Documents)
+
+	result := results.Read{
+		Phase:           phase,
+		BatchSize:       opts.BatchSize,
+		PageSize:        opts.PageSize,
+		Documents:       readStats.Documents,
+		Pages:           readStats.Pages.Count(),
+		FirstDocumentMs: float64(readStats.FirstDocument) / float64(time.Millisecond),
+		HeapBeforeBytes: before,
+		PeakHeapBytes:   peak,
+	}
+	if opts.PageSize > 0 {
+		result.PageKey = opts.PageKey
+	}
+	if readStats.Elapsed > 0 {
+		result.DocsPerSec = float64(readStats.Documents) / readStats.Elapsed.Seconds()
+	}
+	log.Printf("Read %d documents in %s over %d pages: first after %s, %.1f docs/s, peak heap %.1f MiB (%.1f MiB before)",
+		readStats.Documents, readStats.Elapsed, result.Pages, readStats.FirstDocument, result.DocsPerSec,
+		float64(peak)/(1<<20), float64(before)/(1<<20))
+	if cfg.Read.PageSize > 0 {
+		log.Printf("Page latency %s", readStats.Pages.Summary())
+	}
+	return result
+}
+
+// heapSampler records the most heap allocated until stopped.
+type heapSampler struct {
+	before uint64
+	peak   uint64
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+// sampleHeap collects garbage, so the peak is not inflated by what
+// earlier phases left behind, and samples the heap allocated every 10ms
+// through runtime/metrics, which unlike ReadMemStats does not stop the
+// world.
+func sampleHeap() *heapSampler {
+	runtime.GC()
+	h := &heapSampler{stopCh: make(chan struct{}), done: make(chan struct{})}
+	h.before = heapAlloc()
+	h.peak = h.before
+	go func() {
+		defer close(h.done)
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.stopCh:
+				return
+			case <-ticker.C:
+				h.peak = max(h.peak, heapAlloc())
+			}
+		}
+	}()
+	return h
+}
+
+// stop stops sampling and returns the heap allocated before and at its
+// peak.
+func (h *heapSampler) stop() (before, peak uint64) {
+	close(h.stopCh)
+	<-h.done
+	return h.before, max(h.peak, heapAlloc())
+}
+
+func heapAlloc() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
@@ -0,0 +1,35 @@
+package benchmark
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// getReplicaSetStatus asks any reachable member for replSetGetStatus, so it
+// keeps answering while the set has no primary.
+func getReplicaSetStatus(client *mongo.Client, ctx context.Context) (primary string, secondaries []string, err error) {
+	var result struct {
+		Members []struct {
+			Name     string `bson:"name"`
+			StateStr string `bson:"stateStr"`
+		} `bson:"members"`
+	}
+	admin := client.Database("admin", options.Database().SetReadPreference(readpref.Nearest()))
+	err = admin.RunCommand(ctx, bson.D{{Key: "replSetGetStatus", Value: 1}}).Decode(&result)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, member := range result.Members {
+		if member.StateStr == "PRIMARY" {
+			primary = member.Name
+		} else if member.StateStr == "SECONDARY" {
+			secondaries = append(secondaries, member.Name)
+		}
+	}
+	return primary, secondaries, nil
+}
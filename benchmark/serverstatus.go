@@ -0,0 +1,200 @@
+package benchmark
+
+import (
+	"context"
+	"log"
+	"test/monitor"
+	"test/queries"
+	"test/results"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// serverStatusSampler runs serverStatus on every data-bearing member once
+// an interval, each over a direct connection of its own so the samples do
+// not show up in the run's command and pool statistics.
+type serverStatusSampler struct {
+	interval time.Duration
+	mon      *monitor.Monitor
+	members  []*statusMember
+	samples  []results.ServerStatusSample
+	// restarts asks run to start the samples over.
+	restarts chan struct{}
+	// done is closed when run returns.
+	done chan struct{}
+}
+
+type statusMember struct {
+	host   string
+	client *mongo.Client
+	// last is the previous serverStatus, at lastAt; clientOps the commands
+	// the run had sent to the member by then.
+	last      queries.ServerStatus
+	lastAt    time.Time
+	clientOps int64
+}
+
+// newServerStatusSampler connects to the members replSetGetStatus reports
+// as primary or secondary, or to the hosts of uri when it cannot tell.
+func newServerStatusSampler(ctx context.Context, client *mongo.Client, uri string, mon *monitor.Monitor, interval time.Duration) *serverStatusSampler {
+	s := &serverStatusSampler{interval: interval, mon: mon, restarts: make(chan struct{}, 1), done: make(chan struct{})}
+	primary, secondaries, err := getReplicaSetStatus(client, ctx)
+	hosts := append([]string{primary}, secondaries...)
+	if err != nil || primary == "" && len(secondaries) == 0 {
+		hosts = options.Client().ApplyURI(uri).Hosts
+	}
+	for _, host := range hosts {
+		if host == "" {
+			continue
+		}
+		opts := options.Client().ApplyURI(uri).SetHosts([]string{host}).SetDirect(true)
+		opts.ReplicaSet = nil
+		c, err := mongo.Connect(ctx, opts)
+		if err != nil {
+			log.Printf("Failed to connect to %s for serverStatus: %v", host, err)
+			continue
+		}
+		s.members = append(s.members, &statusMember{host: host, client: c})
+	}
+	return s
+}
+
+// run samples every member once an interval until ctx is done. The first
+// round, and the first after a restart, only set the baseline the deltas
+// start from.
+func (s *serverStatusSampler) run(ctx context.Context) {
+	defer close(s.done)
+	s.sample(ctx, time.Now())
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.sample(ctx, now)
+		case <-s.restarts:
+			s.samples = nil
+			for _, m := range s.members {
+				m.lastAt = time.Time{}
+			}
+			s.sample(ctx, time.Now())
+			ticker.Reset(s.interval)
+		}
+	}
+}
+
+// restart makes run drop the samples so far and take a new baseline right
+// away, for when the monitor was reset.
+func (s *serverStatusSampler) restart() {
+	select {
+	case s.restarts <- struct{}{}:
+	default:
+	}
+}
+
+func (s *serverStatusSampler) sample(ctx context.Context, now time.Time) {
+	for _, m := range s.members {
+		statusCtx, cancel := context.WithTimeout(ctx, s.interval)
+		status, err := queries.ReadServerStatus(m.client.Database("admin"), statusCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to get serverStatus of %s: %v", m.host, err)
+			}
+			continue
+		}
+		clientOps := s.clientOps(m.host)
+		// A member that restarted starts its counters over, so its next
+		// sample starts from this one.
+		restarted := status.Network.NumRequests < m.last.Network.NumRequests
+		if !m.lastAt.IsZero() && !restarted {
+			s.samples = append(s.samples, delta(m, status, now, clientOps))
+		}
+		m.last, m.lastAt, m.clientOps = status, now, clientOps
+	}
+}
+
+// clientOps returns how many commands the run has sent to host so far.
+func (s *serverStatusSampler) clientOps(host string) int64 {
+	var n int64
+	for _, name := range monitor.Commands {
+		n += s.mon.Command(name).Hosts[host]
+	}
+	return n
+}
+
+func delta(m *statusMember, status queries.ServerStatus, now time.Time, clientOps int64) results.ServerStatusSample {
+	prev := m.last
+	cache, prevCache := status.WiredTiger.Cache, prev.WiredTiger.Cache
+	tickets := status.Tickets()
+	return results.ServerStatusSample{
+		Time:    now,
+		Host:    m.host,
+		Seconds: now.Sub(m.lastAt).Seconds(),
+
+		Inserts:  status.Opcounters.Insert - prev.Opcounters.Insert,
+		Queries:  status.Opcounters.Query - prev.Opcounters.Query,
+		Updates:  status.Opcounters.Update - prev.Opcounters.Update,
+		Deletes:  status.Opcounters.Delete - prev.Opcounters.Delete,
+		GetMores: status.Opcounters.GetMore - prev.Opcounters.GetMore,
+		Commands: status.Opcounters.Command - prev.Opcounters.Command,
+
+		CacheBytes:           cache.Bytes,
+		CacheDirtyBytes:      cache.DirtyBytes,
+		CacheMaxBytes:        cache.MaxBytes,
+		CacheBytesRead:       cache.BytesRead - prevCache.BytesRead,
+		CacheBytesWritten:    cache.BytesWritten - prevCache.BytesWritten,
+		PagesEvicted:         cache.ModifiedEvicted + cache.UnmodifiedEvicted - prevCache.ModifiedEvicted - prevCache.UnmodifiedEvicted,
+		ApplicationEvictions: cache.ApplicationEvictions - prevCache.ApplicationEvictions,
+
+		ReadTicketsOut:        tickets.Read.Out,
+		ReadTicketsAvailable:  tickets.Read.Available,
+		WriteTicketsOut:       tickets.Write.Out,
+		WriteTicketsAvailable: tickets.Write.Available,
+
+		BytesIn:  status.Network.BytesIn - prev.Network.BytesIn,
+		BytesOut: status.Network.BytesOut - prev.Network.BytesOut,
+		Requests: status.Network.NumRequests - prev.Network.NumRequests,
+
+		DocsInserted: status.Metrics.Document.Inserted - prev.Metrics.Document.Inserted,
+		DocsReturned: status.Metrics.Document.Returned - prev.Metrics.Document.Returned,
+		DocsUpdated:  status.Metrics.Document.Updated - prev.Metrics.Document.Updated,
+		DocsDeleted:  status.Metrics.Document.Deleted - prev.Metrics.Document.Deleted,
+
+		ClientOps: clientOps - m.clientOps,
+	}
+}
+
+// report waits for run to return and returns the samples it took, after
+// logging a line per member.
+func (s *serverStatusSampler) report() []results.ServerStatusSample {
+	<-s.done
+	for _, m := range s.members {
+		var n int
+		var inserts, evicted, appEvictions, peakCache, maxCache int64
+		for _, sample := range s.samples {
+			if sample.Host != m.host {
+				continue
+			}
+			n++
+			inserts += sample.Inserts
+			evicted += sample.PagesEvicted
+			appEvictions += sample.ApplicationEvictions
+			if sample.CacheBytes > peakCache {
+				peakCache, maxCache = sample.CacheBytes, sample.CacheMaxBytes
+			}
+		}
+		log.Printf("serverStatus %s: %d samples, %d inserts, peak cache %d of %d bytes, %d pages evicted, %d by application threads",
+			m.host, n, inserts, peakCache, maxCache, evicted, appEvictions)
+	}
+	return s.samples
+}
+
+func (s *serverStatusSampler) close() {
+	for _, m := range s.members {
+		m.client.Disconnect(context.TODO())
+	}
+}
@@ -0,0 +1,191 @@
+package benchmark
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"test/config"
+	"test/layouts"
+	"test/monitor"
+	"test/queries"
+	"test/stats"
+	"test/workload"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// writer runs the writes of the write phase and of its warmup and cooldown.
+type writer struct {
+	cfg     config.Config
+	layout  layouts.Layout
+	writers []*mongo.Collection
+	docs    *queries.Documents
+	// failover, when set, does the writes so that failed writes are
+	// counted instead of ending the run.
+	failover *failover
+	progress *progress
+	// rampUp is what is left of the open-loop ramp-up: only the first stage
+	// ramps, the ones after it start at the target rate.
+	rampUp time.Duration
+	// failed counts the writes that failed outside of a failover run.
+	failed int64
+	// updated counts the documents the updates of bulkWrite batches
+	// matched.
+	updated int64
+}
+
+// writePhase runs an optional warmup whose measurements are discarded,
+// along with what mon and statuses saw of it, then a measured write run per
+// entry of cfg.Iterations, or a single one lasting cfg.Duration, and then
+// an optional cooldown reported as PhaseCooldown.
+func writePhase(ctx context.Context, cfg config.Config, layout layouts.Layout, writers []*mongo.Collection, docs *queries.Documents, phases *stats.Phases, mon *monitor.Monitor, statuses *serverStatusSampler, fo *failover, prog *progress) {
+	w := &writer{cfg: cfg, layout: layout, writers: writers, docs: docs, failover: fo, progress: prog, rampUp: time.Duration(cfg.Rate.RampUp)}
+
+	if cfg.Warmup > 0 {
+		log.Printf("------ Warmup for %s ------", time.Duration(cfg.Warmup))
+		latency, docs, _ := w.run(ctx, "warmup", 0, time.Duration(cfg.Warmup))
+		log.Printf("Warmup wrote %d documents, discarding latency %s", docs, latency.Summary())
+		mon.Reset()
+		if statuses != nil {
+			statuses.restart()
+		}
+	}
+
+	if cfg.Duration > 0 {
+		w.measure(ctx, config.PhaseWrite, 0, time.Duration(cfg.Duration), phases)
+	} else {
+		for _, n := range cfg.Iterations {
+			w.measure(ctx, config.PhaseWrite, n, 0, phases)
+		}
+	}
+
+	if cfg.Cooldown > 0 {
+		log.Printf("------ Cooldown for %s ------", time.Duration(cfg.Cooldown))
+		w.measure(ctx, config.PhaseCooldown, 0, time.Duration(cfg.Cooldown), phases)
+	}
+}
+
+// measure writes n documents, or for d when n is 0, and records the writes
+// in phase.
+func (w *writer) measure(ctx context.Context, phase string, n int, d time.Duration, phases *stats.Phases) {
+	cfg := w.cfg
+	switch {
+	case n == 0:
+		log.Printf("Writing for %s with %d workers", d, cfg.Workers)
+	case cfg.Batch.Enabled():
+		log.Printf("Writing %d documents in %d %s batches of %d (ordered=%t) with %d workers",
+			n, batchCount(n, cfg.Batch.Size), cfg.Batch.Mode, cfg.Batch.Size, cfg.Batch.Ordered, cfg.Workers)
+	default:
+		log.Printf("Writing %d documents with %d workers", n, cfg.Workers)
+	}
+
+	failedBefore, updatedBefore := atomic.LoadInt64(&w.failed), atomic.LoadInt64(&w.updated)
+	latency, docs, elapsed := w.run(ctx, phase, n, d)
+	phases.Get(phase).Merge(latency)
+	phases.AddElapsed(phase, elapsed)
+	phases.AddDocs(phase, docs)
+
+	elapsedWrite := elapsed.Seconds()
+	log.Printf("MongoWrite took %f for %d iterations", elapsedWrite, docs)
+	log.Printf("Insertions Per Second %f", float64(docs)/elapsedWrite)
+	if cfg.Rate.Enabled() {
+		log.Printf("Achieved %.1f of %.1f target ops/s", float64(latency.Count())/elapsedWrite, cfg.Rate.OpsPerSec)
+	}
+	if failed := atomic.LoadInt64(&w.failed) - failedBefore; failed > 0 {
+		log.Printf("%d writes failed", failed)
+	}
+	if updated := atomic.LoadInt64(&w.updated) - updatedBefore; updated > 0 {
+		log.Printf("Updates matched %d documents", updated)
+	}
+	if cfg.Batch.Enabled() {
+		log.Printf("Batch latency %s", latency.Summary())
+	} else {
+		log.Printf("Insert latency %s", latency.Summary())
+	}
+}
+
+// run writes n documents, or keeps writing for d when n is 0, and returns
+// the latency of every operation, the number of documents written and how
+// long it took. phase only labels the progress.
+func (w *writer) run(ctx context.Context, phase string, n int, d time.Duration) (*stats.Histogram, int64, time.Duration) {
+	cfg := w.cfg
+	operations := n
+	if n > 0 && cfg.Batch.Enabled() {
+		operations = batchCount(n, cfg.Batch.Size)
+	}
+	w.progress.start(phase, operations, d)
+	if d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	var docs int64
+	write := func(ctx context.Context, worker int, i int) {
+		ctx, done := monitor.Track(ctx)
+		if w.failover != nil {
+			err := w.failover.write(ctx, w.layout, w.writers[worker], w.docs)
+			done(err)
+			if err == nil {
+				atomic.AddInt64(&docs, 1)
+			}
+			return
+		}
+		if !cfg.Batch.Enabled() {
+			err := w.layout.Write(ctx, w.writers[worker], w.docs)
+			done(err)
+			if err != nil {
+				w.fail(err)
+				return
+			}
+			atomic.AddInt64(&docs, 1)
+			return
+		}
+		size := cfg.Batch.Size
+		if n > 0 {
+			size = batchLen(n, size, i)
+		}
+		inserted, updated, err := writeBatch(ctx, w.layout, w.writers[worker], w.docs, size, cfg.Batch)
+		done(err)
+		if err != nil {
+			w.fail(err)
+		}
+		atomic.AddInt64(&docs, int64(inserted))
+		atomic.AddInt64(&w.updated, int64(updated))
+	}
+
+	startWrite := time.Now()
+	latency := stats.NewHistogram()
+	if cfg.Rate.Enabled() {
+		log.Printf("Open loop at %.1f ops/s, ramping up over %s", cfg.Rate.OpsPerSec, w.rampUp)
+		sched := workload.Schedule{Rate: cfg.Rate.OpsPerSec, RampUp: w.rampUp}
+		w.rampUp = 0
+		missed, end := workload.Paced(ctx, cfg.Workers, operations, sched, func(ctx context.Context, worker int, i int, due time.Time) {
+			write(ctx, worker, i)
+			w.progress.record(latency.Since(due))
+		})
+		for _, due := range missed {
+			latency.Record(end.Sub(due))
+		}
+		if len(missed) > 0 {
+			log.Printf("%d writes were still queued when the run ended, counted as waiting until then", len(missed))
+		}
+	} else {
+		workload.Run(ctx, cfg.Workers, operations, func(ctx context.Context, worker int, i int) {
+			singleTransactionStartTime := time.Now()
+			write(ctx, worker, i)
+			w.progress.record(latency.Since(singleTransactionStartTime))
+		})
+	}
+	return latency, atomic.LoadInt64(&docs), time.Since(startWrite)
+}
+
+// fail counts a failed write, logging the first one. The writes still
+// running when a timed run ends get a context that is not cancelled, see
+// workload.Run, so every error here is a real failure.
+func (w *writer) fail(err error) {
+	if atomic.AddInt64(&w.failed, 1) == 1 {
+		log.Printf("First failed write: %v", err)
+	}
+}
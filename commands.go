@@ -0,0 +1,431 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"test/benchmark"
+	"test/config"
+	"test/fetch_operations"
+	"test/layouts"
+	"test/results"
+	"time"
+)
+
+// command is a node in the CLI tree. Leaf commands have a run function and
+// accept the shared connection flags; the others only dispatch to their
+// subcommands.
+type command struct {
+	name        string
+	summary     string
+	subcommands []*command
+
+	// layout selects the config.Default a leaf starts from and, when a
+	// scenario is given, the layout that scenario must describe.
+	layout     string
+	collection string
+	writes     bool
+	// needsScenario leaves the layout to the scenario file.
+	needsScenario bool
+	// compares runs the workload against several layouts.
+	compares bool
+	run      func(cfg config.Config)
+	// runFiles is set instead of run by commands that read the files named
+	// on the command line rather than connect.
+	runFiles func(paths []string) error
+}
+
+var root = &command{
+	name: filepath.Base(os.Args[0]),
+	subcommands: []*command{
+		{
+			name:        "write",
+			summary:     "Insert documents and report insertion throughput",
+			subcommands: layoutCommands(),
+		},
+		{
+			name:    "timeseries",
+			summary: "Write into a time-series collection and read it back",
+			layout:  layouts.Timeseries,
+			writes:  true,
+			run:     runLayout,
+		},
+		{
+			name:          "run",
+			summary:       "Run the phases of a scenario file against its layout",
+			writes:        true,
+			needsScenario: true,
+			run:           runLayout,
+		},
+		{
+			name:     "compare",
+			summary:  "Run the same workload against several layouts and compare them",
+			writes:   true,
+			compares: true,
+			run:      runCompare,
+		},
+		{
+			name:    "fetch",
+			summary: "Run the update and read-by-device queries",
+			subcommands: []*command{
+				{
+					name:       "clustered",
+					summary:    "Query the clustered collection",
+					collection: "Imported",
+					run:        fetch_operations.FetchClustered,
+				},
+				{
+					name:       "nonclustered",
+					summary:    "Query the non-clustered collection",
+					collection: "AdvertisementHistoryMDB",
+					run:        fetch_operations.FetchNonClustered,
+				},
+			},
+		},
+		{
+			name:    "events",
+			summary: "Read events logs",
+			subcommands: []*command{
+				{
+					name:     "analyze",
+					summary:  "Report primary changes, time in Unknown/RSOther and RTT per member",
+					runFiles: runAnalyzeEvents,
+				},
+			},
+		},
+		{
+			name:       "explain",
+			summary:    "Print the executionStats explain of a find sorted by deviceId",
+			collection: "AdvertisementHistoryMDB",
+			run:        runExplain,
+		},
+		{
+			name:       "indexes",
+			summary:    "List the indexes of a collection",
+			collection: "AdvertisementHistoryMDB",
+			run:        runIndexes,
+		},
+	},
+}
+
+// layoutCommands returns a write subcommand for every registered layout.
+func layoutCommands() []*command {
+	var commands []*command
+	for _, layout := range layouts.All() {
+		commands = append(commands, &command{
+			name:    layout.Name(),
+			summary: layout.Describe(),
+			layout:  layout.Name(),
+			writes:  true,
+			run:     runLayout,
+		})
+	}
+	return commands
+}
+
+func run(args []string) error {
+	return root.execute(nil, args)
+}
+
+func (c *command) execute(path []string, args []string) error {
+	path = append(path, c.name)
+	if c.runFiles != nil {
+		return c.executeFiles(path, args)
+	}
+	if c.run == nil {
+		if len(args) == 0 {
+			c.usage(path)
+			return fmt.Errorf("%s: missing command", strings.Join(path, " "))
+		}
+		if isHelp(args[0]) {
+			c.usage(path)
+			return flag.ErrHelp
+		}
+		for _, sub := range c.subcommands {
+			if sub.name == args[0] {
+				return sub.execute(path, args[1:])
+			}
+		}
+		c.usage(path)
+		return fmt.Errorf("%s: unknown command %q", strings.Join(path, " "), args[0])
+	}
+
+	defaults := config.Default(c.layout)
+	if c.compares {
+		defaults = config.DefaultComparison()
+	}
+	if c.collection != "" {
+		defaults.Collection = c.collection
+	}
+
+	var flags config.Config
+	fs := flag.NewFlagSet(strings.Join(path, " "), flag.ContinueOnError)
+	scenario := fs.String("scenario", "", "YAML or JSON scenario file; flags given explicitly override its values")
+	fs.StringVar(&flags.URI, "uri", defaults.URI, "MongoDB connection string")
+	fs.StringVar(&flags.Database, "db", defaults.Database, "database name")
+	if !c.compares {
+		fs.StringVar(&flags.Collection, "collection", defaults.Collection, "collection name")
+	}
+	fs.StringVar(&flags.Options.ResultsDir, "results", defaults.Options.ResultsDir, "directory for the JSON and CSV results, empty disables them")
+	fs.StringVar(&flags.Tracing.File, "trace-file", defaults.Tracing.File, "file to append a span per operation to, as JSON lines")
+	fs.StringVar(&flags.Tracing.Endpoint, "trace-endpoint", defaults.Tracing.Endpoint, "OTLP/HTTP collector to post spans to, such as http://localhost:4318")
+	fs.Float64Var(&flags.Tracing.Ratio, "trace-ratio", defaults.Tracing.Ratio, "share of operations to trace")
+	iterations := intList(defaults.Iterations)
+	clusterKey := stringList(defaults.ClusterKey)
+	readBatch := int32Value(defaults.Read.BatchSize)
+	if c.writes {
+		fs.Var(&iterations, "iterations", "comma separated iteration counts, one write run per entry")
+		fs.DurationVar((*time.Duration)(&flags.Duration), "duration", time.Duration(defaults.Duration), "write for this long instead of -iterations")
+		fs.DurationVar((*time.Duration)(&flags.Warmup), "warmup", time.Duration(defaults.Warmup), "write for this long first and discard the measurements")
+		fs.DurationVar((*time.Duration)(&flags.Cooldown), "cooldown", time.Duration(defaults.Cooldown), "keep writing for this long after the write phase, reported separately")
+		fs.IntVar(&flags.Workers, "workers", defaults.Workers, "number of concurrent writers")
+		fs.BoolVar(&flags.WorkerAffinity, "affinity", defaults.WorkerAffinity, "give every writer its own single-connection client")
+		fs.IntVar(&flags.Batch.Size, "batch", defaults.Batch.Size, "documents per batch, 0 inserts one document at a time")
+		fs.StringVar(&flags.Batch.Mode, "batch-mode", defaults.Batch.Mode, "insertMany or bulkWrite")
+		fs.BoolVar(&flags.Batch.Ordered, "ordered", defaults.Batch.Ordered, "send batches as ordered writes")
+		fs.Float64Var(&flags.Batch.UpdateRatio, "update-ratio", defaults.Batch.UpdateRatio, "share of bulkWrite operations that are updates")
+		fs.Float64Var(&flags.Rate.OpsPerSec, "rate", defaults.Rate.OpsPerSec, "open-loop target write ops/sec, 0 runs closed-loop")
+		fs.DurationVar((*time.Duration)(&flags.Rate.RampUp), "ramp-up", time.Duration(defaults.Rate.RampUp), "time to ramp up linearly to -rate")
+		fs.Int64Var(&flags.Data.Devices, "devices", defaults.Data.Devices, "number of distinct deviceIds, 0 gives every document its own")
+		fs.StringVar(&flags.Data.Popularity, "popularity", defaults.Data.Popularity, "how documents spread over the devices: uniform or zipf")
+		fs.Int64Var(&flags.Data.Seed, "seed", defaults.Data.Seed, "seed that makes the generated documents reproducible, 0 picks one")
+		fs.BoolVar(&flags.Options.Live, "live", defaults.Options.Live, "show a live dashboard refreshed every second")
+		fs.StringVar(&flags.Options.MetricsAddr, "metrics", defaults.Options.MetricsAddr, "serve Prometheus metrics at http://<host:port>/metrics, empty disables it")
+		fs.DurationVar((*time.Duration)(&flags.ServerStatusInterval), "server-status", time.Duration(defaults.ServerStatusInterval), "sample serverStatus on every member this often, 0 disables it")
+		fs.BoolVar(&flags.Failover.Enabled, "failover", defaults.Failover.Enabled, "watch for a primary election during the write phase and report its impact")
+		fs.Var(&clusterKey, "cluster-key", "comma separated fields the _id of clustered documents is made of, ending with reqRefNo, such as deviceId,timeStamp,reqRefNo; clustered layout only")
+		fs.Var(&readBatch, "read-batch", "documents per cursor batch of the read phases, 0 leaves it to the server")
+		fs.Int64Var(&flags.Read.PageSize, "page-size", defaults.Read.PageSize, "read in keyset pages of this many documents, 0 reads through one cursor")
+		fs.StringVar(&flags.Read.PageKey, "page-key", defaults.Read.PageKey, "what read pages are keyed on: _id or deviceId")
+		fs.Int64Var(&flags.Read.DeviceID, "read-device", defaults.Read.DeviceID, "read only the history of this device, 0 reads every document")
+		fs.Int64Var(&flags.Read.Limit, "read-limit", defaults.Read.Limit, "documents a read phase reads at most, 0 for all")
+	}
+	layouts := stringList(defaults.Layouts)
+	if c.compares {
+		fs.Var(&layouts, "layouts", "comma separated layouts to compare")
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "%s\n\nUsage: %s [flags]\n\nFlags:\n", c.summary, fs.Name())
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return fmt.Errorf("%s: unexpected arguments %q", fs.Name(), fs.Args())
+	}
+
+	cfg := defaults
+	if *scenario != "" {
+		loaded, err := config.Load(*scenario)
+		if err != nil {
+			return err
+		}
+		if c.layout != "" && loaded.Layout != c.layout {
+			return fmt.Errorf("%s: scenario %s describes layout %q, not %q", fs.Name(), *scenario, loaded.Layout, c.layout)
+		}
+		cfg = loaded
+	} else if c.needsScenario {
+		fs.Usage()
+		return fmt.Errorf("%s: -scenario is required", fs.Name())
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "uri":
+			cfg.URI = flags.URI
+		case "db":
+			cfg.Database = flags.Database
+		case "collection":
+			cfg.Collection = flags.Collection
+		case "results":
+			cfg.Options.ResultsDir = flags.Options.ResultsDir
+		case "trace-file":
+			cfg.Tracing.File = flags.Tracing.File
+		case "trace-endpoint":
+			cfg.Tracing.Endpoint = flags.Tracing.Endpoint
+		case "trace-ratio":
+			cfg.Tracing.Ratio = flags.Tracing.Ratio
+		case "iterations":
+			cfg.Iterations = iterations
+		case "workers":
+			cfg.Workers = flags.Workers
+		case "affinity":
+			cfg.WorkerAffinity = flags.WorkerAffinity
+		case "batch":
+			cfg.Batch.Size = flags.Batch.Size
+		case "batch-mode":
+			cfg.Batch.Mode = flags.Batch.Mode
+		case "ordered":
+			cfg.Batch.Ordered = flags.Batch.Ordered
+		case "update-ratio":
+			cfg.Batch.UpdateRatio = flags.Batch.UpdateRatio
+		case "duration":
+			cfg.Duration = flags.Duration
+		case "warmup":
+			cfg.Warmup = flags.Warmup
+		case "cooldown":
+			cfg.Cooldown = flags.Cooldown
+		case "rate":
+			cfg.Rate.OpsPerSec = flags.Rate.OpsPerSec
+		case "ramp-up":
+			cfg.Rate.RampUp = flags.Rate.RampUp
+		case "devices":
+			cfg.Data.Devices = flags.Data.Devices
+		case "popularity":
+			cfg.Data.Popularity = flags.Data.Popularity
+		case "seed":
+			cfg.Data.Seed = flags.Data.Seed
+		case "live":
+			cfg.Options.Live = flags.Options.Live
+		case "metrics":
+			cfg.Options.MetricsAddr = flags.Options.MetricsAddr
+		case "server-status":
+			cfg.ServerStatusInterval = flags.ServerStatusInterval
+		case "failover":
+			cfg.Failover.Enabled = flags.Failover.Enabled
+		case "cluster-key":
+			cfg.ClusterKey = clusterKey
+		case "read-batch":
+			cfg.Read.BatchSize = int32(readBatch)
+		case "page-size":
+			cfg.Read.PageSize = flags.Read.PageSize
+			if *scenario == "" && cfg.Read.PageSize > 0 {
+				// Pages are read in key order, leaving readSorted nothing
+				// to sort.
+				phases := cfg.Phases[:0:0]
+				for _, phase := range cfg.Phases {
+					if phase != config.PhaseReadSorted {
+						phases = append(phases, phase)
+					}
+				}
+				cfg.Phases = phases
+			}
+		case "page-key":
+			cfg.Read.PageKey = flags.Read.PageKey
+		case "read-device":
+			cfg.Read.DeviceID = flags.Read.DeviceID
+		case "read-limit":
+			cfg.Read.Limit = flags.Read.Limit
+		case "layouts":
+			cfg.Layouts = layouts
+		}
+	})
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("%s: %w", fs.Name(), err)
+	}
+
+	c.run(cfg)
+	return nil
+}
+
+func (c *command) executeFiles(path []string, args []string) error {
+	fs := flag.NewFlagSet(strings.Join(path, " "), flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "%s\n\nUsage: %s <file>...\n", c.summary, fs.Name())
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("%s: missing file", fs.Name())
+	}
+	return c.runFiles(fs.Args())
+}
+
+func (c *command) usage(path []string) {
+	w := flag.CommandLine.Output()
+	if c.summary != "" {
+		fmt.Fprintf(w, "%s\n\n", c.summary)
+	}
+	fmt.Fprintf(w, "Usage: %s <command> [flags]\n\nCommands:\n", strings.Join(path, " "))
+	for _, sub := range c.subcommands {
+		fmt.Fprintf(w, "  %-14s %s\n", sub.name, sub.summary)
+	}
+	fmt.Fprintf(w, "\nRun '%s <command> --help' for the flags of a command.\n", strings.Join(path, " "))
+}
+
+func runLayout(cfg config.Config) {
+	if cfg.Layout == "" {
+		log.Fatalf("Scenario %s has no layout, run it with compare", cfg.Name)
+	}
+	benchmark.Run(cfg)
+}
+
+func runCompare(cfg config.Config) {
+	var runs []*results.Result
+	for _, layout := range cfg.Layouts {
+		log.Printf("------ Comparing %s ------", layout)
+		runs = append(runs, benchmark.Run(cfg.ForLayout(layout)))
+	}
+	if err := results.WriteComparison(os.Stdout, runs); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func isHelp(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help" || arg == "help"
+}
+
+// intList is a flag.Value for comma separated integers such as "1000,100000".
+type intList []int
+
+func (l *intList) String() string {
+	parts := make([]string, len(*l))
+	for i, n := range *l {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *intList) Set(value string) error {
+	var parsed []int
+	for _, part := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid iteration count %q", part)
+		}
+		parsed = append(parsed, n)
+	}
+	*l = parsed
+	return nil
+}
+
+// stringList is a flag.Value for comma separated names.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	var parsed []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parsed = append(parsed, part)
+		}
+	}
+	*l = parsed
+	return nil
+}
+
+// int32Value is a flag.Value for int32 fields.
+type int32Value int32
+
+func (v *int32Value) String() string {
+	return strconv.FormatInt(int64(*v), 10)
+}
+
+func (v *int32Value) Set(value string) error {
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return err
+	}
+	*v = int32Value(n)
+	return nil
+}
@@ -0,0 +1,20 @@
+package config
+
+import "time"
+
+// Duration is a time.Duration written as a string such as "30s" or "5m" in
+// scenario files.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Load reads a scenario file. Files ending in .json are decoded as JSON,
+// everything else as YAML. Unknown keys are rejected so typos do not
+// silently fall back to defaults. Values missing from the file are taken
+// from Default for the scenario's layout, or from DefaultComparison when
+// the scenario only lists layouts to compare.
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("scenario: %w", err)
+	}
+
+	var layout struct {
+		Layout  string   `json:"layout" yaml:"layout"`
+		Layouts []string `json:"layouts" yaml:"layouts"`
+	}
+	if err := unmarshal(path, data, &layout, false); err != nil {
+		return Config{}, fmt.Errorf("scenario %s: %w", path, err)
+	}
+
+	cfg := Default(layout.Layout)
+	if layout.Layout == "" && len(layout.Layouts) > 0 {
+		cfg = DefaultComparison()
+	}
+	if err := unmarshal(path, data, &cfg, true); err != nil {
+		return Config{}, fmt.Errorf("scenario %s: %w", path, err)
+	}
+	if cfg.Name == "" {
+		cfg.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if cfg.Layout == "" && len(cfg.Layouts) == 0 {
+		return Config{}, fmt.Errorf("scenario %s: layout or layouts is required", path)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("scenario %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func unmarshal(path string, data []byte, v any, strict bool) error {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		if strict {
+			dec.DisallowUnknownFields()
+		}
+		return dec.Decode(v)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(strict)
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
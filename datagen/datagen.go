@@ -0,0 +1,222 @@
+// Package datagen generates the varying fields of AdvertisementHistory
+// documents as described by a config.Data. Document n only depends on the
+// seed and on n, so the workers generating documents concurrently need no
+// shared random state.
+package datagen
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"test/config"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Fields are the generated fields of one document.
+type Fields struct {
+	// ID is zero, leaving the _id to the driver, unless the data is seeded.
+	ID              primitive.ObjectID
+	DeviceID        int64
+	RequestRefNo    string
+	RRN             string
+	Amount          string
+	TransactionType int8
+	// TimeStamp is when the transaction happened.
+	TimeStamp time.Time
+}
+
+// Generator is safe for concurrent use.
+type Generator struct {
+	cfg   config.Data
+	seed  int64
+	start time.Time
+	// seeded is set when cfg.Seed was, making _ids and timestamps
+	// deterministic too.
+	seeded bool
+	// types and cumulative are the transaction types and their running
+	// weight totals, for picking a type by weight.
+	types      []int8
+	cumulative []float64
+}
+
+// Epoch is the Start of seeded data that does not set one.
+var Epoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Tick is how far apart seeded documents are generated on the simulated
+// clock their _ids, and their timestamps when there is no Period, come
+// from: document n is generated n ticks after Start.
+const Tick = time.Millisecond
+
+// New returns a generator for cfg. Seeded data is spread over the
+// cfg.Period before cfg.Start, or Epoch; otherwise New picks a seed from the
+// clock and spreads the documents over the cfg.Period before now.
+func New(cfg config.Data) *Generator {
+	g := &Generator{cfg: cfg, seed: cfg.Seed, start: cfg.Start, seeded: cfg.Seed != 0}
+	switch {
+	case !g.seeded:
+		g.seed, g.start = time.Now().UnixNano(), time.Now()
+	case g.start.IsZero():
+		g.start = Epoch
+	}
+	weighted := append([]config.Weighted(nil), cfg.TransactionTypes...)
+	sort.SliceStable(weighted, func(i, j int) bool { return weighted[i].Type < weighted[j].Type })
+	var total float64
+	for _, w := range weighted {
+		total += w.Weight
+		g.types = append(g.types, w.Type)
+		g.cumulative = append(g.cumulative, total)
+	}
+	return g
+}
+
+// Seed returns the seed of the documents, which reproduces their fields
+// other than _id and the timestamps when the data was not seeded.
+func (g *Generator) Seed() int64 {
+	return g.seed
+}
+
+// Fields returns the fields of document n, counting from 1.
+func (g *Generator) Fields(n int64) Fields {
+	r := rand.New(newSource(g.seed, n))
+	f := Fields{
+		DeviceID:        g.device(r, n),
+		RequestRefNo:    g.reqRefNo(r, n),
+		RRN:             g.rrn(n),
+		Amount:          fmt.Sprintf("%.2f", g.amount(r)),
+		TransactionType: g.transactionType(r),
+		TimeStamp:       g.timeStamp(r, n),
+	}
+	if g.seeded {
+		f.ID = g.objectID(g.clock(n), n)
+	}
+	return f
+}
+
+// Update returns which of n written documents the u-th update of a run
+// targets, counting from 0.
+func (g *Generator) Update(u, n int64) int64 {
+	return rand.New(newSource(^g.seed, u)).Int63n(n)
+}
+
+// clock returns when seeded document n is generated, see Tick.
+func (g *Generator) clock(n int64) time.Time {
+	return g.start.Add(time.Duration(n) * Tick)
+}
+
+// objectID returns an ObjectID made like the driver's, from t, a value of
+// the seed in place of the random machine part and n as the counter, so it
+// is unique for the first 2^32 documents and grows with n.
+func (g *Generator) objectID(t time.Time, n int64) primitive.ObjectID {
+	var id primitive.ObjectID
+	binary.BigEndian.PutUint32(id[0:4], uint32(t.Unix()))
+	binary.BigEndian.PutUint32(id[4:8], uint32(newSource(g.seed, 0).Uint64()))
+	binary.BigEndian.PutUint32(id[8:12], uint32(n))
+	return id
+}
+
+func (g *Generator) device(r *rand.Rand, n int64) int64 {
+	switch {
+	case g.cfg.Devices == 0:
+		return n
+	case g.cfg.Popularity == config.PopularityZipf && g.cfg.Devices > 1:
+		return int64(rand.NewZipf(r, g.cfg.ZipfS, 1, uint64(g.cfg.Devices-1)).Uint64()) + 1
+	default:
+		return r.Int63n(g.cfg.Devices) + 1
+	}
+}
+
+// pspPrefixes are the payment service provider prefixes of upi reqRefNos.
+var pspPrefixes = []string{"AXI", "HDF", "ICI", "KKB", "PTM", "SBI", "YBL"}
+
+func (g *Generator) reqRefNo(r *rand.Rand, n int64) string {
+	switch g.cfg.ReqRefNo {
+	case config.ReqRefNoUUID:
+		var b [16]byte
+		r.Read(b[:])
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	case config.ReqRefNoUPI:
+		return fmt.Sprintf("%s%016X%016X", pspPrefixes[r.Intn(len(pspPrefixes))], r.Uint64(), r.Uint64())
+	default:
+		return fmt.Sprintf("REQ%d", n)
+	}
+}
+
+// rrn returns an ISO 8583 retrieval reference number: the last digit of
+// the year, the day of the year and the hour the run started, then n as a
+// six digit sequence.
+func (g *Generator) rrn(n int64) string {
+	if g.cfg.RRN != config.RRNISO8583 {
+		return "ddff"
+	}
+	return fmt.Sprintf("%d%03d%02d%06d", g.start.Year()%10, g.start.YearDay(), g.start.Hour(), n%1000000)
+}
+
+func (g *Generator) amount(r *rand.Rand) float64 {
+	a := g.cfg.Amount
+	switch a.Distribution {
+	case config.AmountUniform:
+		return a.Min + r.Float64()*(a.Max-a.Min)
+	case config.AmountLogNormal:
+		v := a.Median * math.Exp(a.Sigma*r.NormFloat64())
+		if a.Max > 0 {
+			v = math.Min(math.Max(v, a.Min), a.Max)
+		}
+		return v
+	default:
+		return a.Value
+	}
+}
+
+func (g *Generator) transactionType(r *rand.Rand) int8 {
+	if len(g.types) == 0 {
+		return 3
+	}
+	pick := r.Float64() * g.cumulative[len(g.cumulative)-1]
+	i := sort.Search(len(g.cumulative), func(i int) bool { return g.cumulative[i] > pick })
+	return g.types[i]
+}
+
+func (g *Generator) timeStamp(r *rand.Rand, n int64) time.Time {
+	switch {
+	case g.cfg.Period > 0:
+	case g.seeded:
+		return g.clock(n)
+	default:
+		return time.Now()
+	}
+	return g.start.Add(-time.Duration(r.Int63n(int64(g.cfg.Period))))
+}
+
+// source is a splitmix64 generator: cheap to seed, so every document can
+// have its own.
+type source struct {
+	state uint64
+}
+
+func newSource(seed, n int64) *source {
+	s := &source{state: uint64(seed) ^ uint64(n)*0x9e3779b97f4a7c15}
+	s.Uint64()
+	return s
+}
+
+func (s *source) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	z := s.state
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return z ^ z>>31
+}
+
+func (s *source) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (s *source) Seed(seed int64) {
+	s.state = uint64(seed)
+}
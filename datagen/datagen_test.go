@@ -0,0 +1,103 @@
+package datagen
+
+import (
+	"bytes"
+	"test/config"
+	"testing"
+	"time"
+)
+
+func TestDeterministic(t *testing.T) {
+	realistic := config.Default("").Data
+	realistic.Devices = 100
+	realistic.Popularity = config.PopularityZipf
+	realistic.ZipfS = 1.1
+	realistic.ReqRefNo = config.ReqRefNoUPI
+	realistic.RRN = config.RRNISO8583
+	realistic.Amount = config.Amount{Distribution: config.AmountLogNormal, Median: 500, Sigma: 1, Min: 1, Max: 100000}
+	realistic.TransactionTypes = []config.Weighted{{Type: 1, Weight: 1}, {Type: 3, Weight: 3}}
+	realistic.Period = config.Duration(720 * time.Hour)
+
+	tests := []struct {
+		name string
+		data config.Data
+	}{
+		{"defaults", config.Default("").Data},
+		{"realistic", realistic},
+	}
+	for _, tt := range tests {
+		tt.data.Seed = 42
+		a, b := New(tt.data), New(tt.data)
+		other := tt.data
+		other.Seed = 43
+		c := New(other)
+		differs := false
+		for n := int64(1); n <= 1000; n++ {
+			if fa, fb := a.Fields(n), b.Fields(n); fa != fb {
+				t.Fatalf("%s: document %d differs with the same seed: %+v and %+v", tt.name, n, fa, fb)
+			}
+			if a.Fields(n) != c.Fields(n) {
+				differs = true
+			}
+		}
+		if !differs {
+			t.Errorf("%s: seeds 42 and 43 generate the same documents", tt.name)
+		}
+	}
+}
+
+func TestSeededIDsGrow(t *testing.T) {
+	tests := []struct {
+		name   string
+		period config.Duration
+	}{
+		{"no period", 0},
+		{"period", config.Duration(24 * time.Hour)},
+	}
+	for _, tt := range tests {
+		data := config.Default("").Data
+		data.Seed = 7
+		data.Period = tt.period
+		g := New(data)
+		prev := g.Fields(1)
+		for n := int64(2); n <= 5000; n++ {
+			f := g.Fields(n)
+			if bytes.Compare(f.ID[:], prev.ID[:]) <= 0 {
+				t.Fatalf("%s: _id of document %d, %s, is not above that of document %d, %s", tt.name, n, f.ID.Hex(), n-1, prev.ID.Hex())
+			}
+			if tt.period == 0 && !f.TimeStamp.After(prev.TimeStamp) {
+				t.Fatalf("%s: timestamp of document %d, %s, is not after %s", tt.name, n, f.TimeStamp, prev.TimeStamp)
+			}
+			prev = f
+		}
+		if want := Epoch.Add(5000 * Tick); !prev.ID.Timestamp().Equal(want.Truncate(time.Second)) {
+			t.Errorf("%s: _id of document 5000 is stamped %s, want %s", tt.name, prev.ID.Timestamp(), want)
+		}
+	}
+}
+
+func TestUnseeded(t *testing.T) {
+	g := New(config.Default("").Data)
+	if g.Seed() == 0 {
+		t.Error("no seed picked")
+	}
+	if f := g.Fields(1); !f.ID.IsZero() {
+		t.Errorf("unseeded document has _id %s", f.ID.Hex())
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	data := config.Default("").Data
+	data.Seed = 1
+	g := New(data)
+	tests := []struct{ u, n int64 }{{1, 1}, {2, 1}, {1, 10}, {7, 10}, {1000, 3}}
+	for _, tt := range tests {
+		got := g.Update(tt.u, tt.n)
+		if got < 0 || got >= tt.n {
+			t.Errorf("Update(%d, %d) = %d, want in [0, %d)", tt.u, tt.n, got, tt.n)
+		}
+		if again := g.Update(tt.u, tt.n); again != got {
+			t.Errorf("Update(%d, %d) = %d, then %d", tt.u, tt.n, got, again)
+		}
+	}
+}
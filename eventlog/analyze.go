@@ -0,0 +1,183 @@
+package eventlog
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"test/monitor"
+	"test/stats"
+	"text/tabwriter"
+	"time"
+)
+
+// Topology is what the events of one driver topology, that is one client
+// and so usually one run, say about the replica set.
+type Topology struct {
+	ID     string
+	RunID  string
+	First  time.Time
+	Last   time.Time
+	Events int
+	// Untimed is set when some of the events had no time of their own, so
+	// the times of the members' states, and of the primary changes, are
+	// not known.
+	Untimed bool
+	// PrimaryChanges lists every time the set lost its primary or got one.
+	PrimaryChanges []PrimaryChange
+	Members        []*Member
+}
+
+// PrimaryChange is a primary stepping down, From set and To empty, or one
+// being elected, To set and From the previous primary if there was one.
+type PrimaryChange struct {
+	Time time.Time
+	From string
+	To   string
+}
+
+// Member is what the events say about one host.
+type Member struct {
+	Host   string
+	Events int
+	// Time adds up, per server type, how long the host had that type
+	// between its first event and the topology's last one.
+	Time map[string]time.Duration
+	RTT  *stats.Histogram
+
+	state string
+	since time.Time
+}
+
+// Analyze groups events by topology ID, in order of their first event.
+func Analyze(events []monitor.ServerEvent) []*Topology {
+	read := make([]Event, len(events))
+	for i, evt := range events {
+		read[i] = Event{ServerEvent: evt}
+	}
+	return AnalyzeLog(read)
+}
+
+// AnalyzeLog is Analyze for the events Read returns.
+func AnalyzeLog(events []Event) []*Topology {
+	byID := map[string]*Topology{}
+	var topologies []*Topology
+	primaries := map[string]map[string]bool{}
+	lastPrimary := map[string]string{}
+	for _, evt := range events {
+		t, ok := byID[evt.TopologyID]
+		if !ok {
+			t = &Topology{ID: evt.TopologyID, RunID: evt.RunID, First: evt.Time}
+			byID[evt.TopologyID] = t
+			topologies = append(topologies, t)
+			primaries[t.ID] = map[string]bool{}
+		}
+		t.Events++
+		t.Untimed = t.Untimed || evt.Untimed
+		if evt.Time.After(t.Last) {
+			t.Last = evt.Time
+		}
+
+		m := t.member(evt.Address)
+		m.Events++
+		if m.state != "" {
+			m.Time[m.state] += evt.Time.Sub(m.since)
+		}
+		m.state, m.since = evt.NewType, evt.Time
+		if evt.AvgRTTMs > 0 {
+			m.RTT.Record(time.Duration(evt.AvgRTTMs * float64(time.Millisecond)))
+		}
+
+		// A set is seen through both its seed list and its member names,
+		// so the same primary can show up under two hosts. Only count a
+		// change when no host was primary before.
+		current := primaries[t.ID]
+		switch {
+		case evt.NewType == "RSPrimary" && !current[evt.Address]:
+			if len(current) == 0 {
+				t.PrimaryChanges = append(t.PrimaryChanges, PrimaryChange{Time: evt.Time, From: lastPrimary[t.ID], To: evt.Address})
+			}
+			current[evt.Address] = true
+			lastPrimary[t.ID] = evt.Address
+		case evt.NewType != "RSPrimary" && current[evt.Address]:
+			delete(current, evt.Address)
+			if len(current) == 0 {
+				t.PrimaryChanges = append(t.PrimaryChanges, PrimaryChange{Time: evt.Time, From: evt.Address})
+			}
+		}
+	}
+
+	for _, t := range topologies {
+		for _, m := range t.Members {
+			m.Time[m.state] += t.Last.Sub(m.since)
+		}
+		sort.Slice(t.Members, func(i, j int) bool { return t.Members[i].Host < t.Members[j].Host })
+	}
+	sort.SliceStable(topologies, func(i, j int) bool { return topologies[i].First.Before(topologies[j].First) })
+	return topologies
+}
+
+func (t *Topology) member(host string) *Member {
+	for _, m := range t.Members {
+		if m.Host == host {
+			return m
+		}
+	}
+	m := &Member{Host: host, Time: map[string]time.Duration{}, RTT: stats.NewHistogram()}
+	t.Members = append(t.Members, m)
+	return m
+}
+
+// WriteReport prints a section per topology: its primary changes and a
+// table with the time each member spent Unknown and RSOther and its RTT.
+// Those times read n/a for untimed topologies.
+func WriteReport(out io.Writer, topologies []*Topology) error {
+	for _, t := range topologies {
+		fmt.Fprintf(out, "Topology %s", t.ID)
+		if t.RunID != "" {
+			fmt.Fprintf(out, " (run %s)", t.RunID)
+		}
+		fmt.Fprintf(out, ": %d events from %s to %s\n", t.Events, t.First.Format(time.RFC3339), t.Last.Format(time.RFC3339))
+		if t.Untimed {
+			fmt.Fprintln(out, "  legacy log: times are when the lines were written, not when the servers changed")
+		}
+
+		if len(t.PrimaryChanges) == 0 {
+			fmt.Fprintln(out, "  no primary seen")
+		}
+		for _, c := range t.PrimaryChanges {
+			switch {
+			case c.To == "":
+				fmt.Fprintf(out, "  %s primary %s lost\n", c.Time.Format(time.RFC3339), c.From)
+			case c.From == "":
+				fmt.Fprintf(out, "  %s primary %s\n", c.Time.Format(time.RFC3339), c.To)
+			default:
+				fmt.Fprintf(out, "  %s primary %s -> %s\n", c.Time.Format(time.RFC3339), c.From, c.To)
+			}
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "member\tevents\tUnknown\tRSOther\tRTT min ms\tmean ms\tp50 ms\tp99 ms\tmax ms\t")
+		for _, m := range t.Members {
+			rtt := m.RTT.Summary()
+			unknown, other := m.Time["Unknown"].String(), m.Time["RSOther"].String()
+			if t.Untimed {
+				unknown, other = untimed, untimed
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t\n",
+				m.Host, m.Events, unknown, other,
+				ms(rtt.Min), ms(rtt.Mean), ms(rtt.P50), ms(rtt.P99), ms(rtt.Max))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(out)
+	}
+	return nil
+}
+
+// untimed stands for the state times of untimed topologies.
+const untimed = "n/a (no per-event time)"
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,90 @@
+package eventlog
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadLegacy(t *testing.T) {
+	file, err := os.Open("testdata/events.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	events, skipped, err := Read(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 || skipped != 1 {
+		t.Fatalf("read %d events, skipped %d, want 4 and 1", len(events), skipped)
+	}
+	tests := []struct {
+		address, previous, next, err string
+		rttMs                        float64
+	}{
+		{"mongo-1:27017", "Unknown", "RSPrimary", "", 1.617},
+		{"mongo-2:27017", "Unknown", "RSSecondary", "", 0.985636},
+		{"mongo-1:27017", "RSPrimary", "Unknown", "connection refused", 0},
+		{"mongo-2:27017", "RSSecondary", "RSPrimary", "", 1.002},
+	}
+	for i, tt := range tests {
+		evt := events[i]
+		if !evt.Untimed || evt.TopologyID != "666fd8b3e63cb158cabfbf25" {
+			t.Errorf("event %d: untimed %t, topology %s", i, evt.Untimed, evt.TopologyID)
+		}
+		if evt.Address != tt.address || evt.PreviousType != tt.previous || evt.NewType != tt.next || evt.Error != tt.err || evt.AvgRTTMs != tt.rttMs {
+			t.Errorf("event %d = %+v, want %+v", i, evt.ServerEvent, tt)
+		}
+	}
+}
+
+func TestAnalyzeLegacy(t *testing.T) {
+	file, err := os.Open("testdata/events.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	events, _, err := Read(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topologies := AnalyzeLog(events)
+	if len(topologies) != 1 || !topologies[0].Untimed {
+		t.Fatalf("want a single untimed topology, got %+v", topologies)
+	}
+	if changes := topologies[0].PrimaryChanges; len(changes) != 3 || changes[2].From != "mongo-1:27017" || changes[2].To != "mongo-2:27017" {
+		t.Errorf("primary changes %+v", changes)
+	}
+
+	var report strings.Builder
+	if err := WriteReport(&report, topologies); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(report.String(), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "mongo-") && !strings.Contains(line, untimed) {
+			t.Errorf("member line without %q: %s", untimed, line)
+		}
+	}
+}
+
+func TestAnalyzeTimed(t *testing.T) {
+	events, _, err := Read(strings.NewReader(strings.Join([]string{
+		`{"time":"2024-06-17T12:03:20Z","address":"mongo-1:27017","topologyId":"t1","previousType":"Unknown","newType":"RSPrimary"}`,
+		`{"time":"2024-06-17T12:03:30Z","address":"mongo-1:27017","topologyId":"t1","previousType":"RSPrimary","newType":"Unknown"}`,
+		`{"time":"2024-06-17T12:03:45Z","address":"mongo-1:27017","topologyId":"t1","previousType":"Unknown","newType":"RSOther"}`,
+		`{"time":"2024-06-17T12:04:00Z","address":"mongo-1:27017","topologyId":"t1","previousType":"RSOther","newType":"RSPrimary"}`,
+	}, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	topologies := AnalyzeLog(events)
+	if len(topologies) != 1 || topologies[0].Untimed {
+		t.Fatalf("want a single timed topology, got %+v", topologies)
+	}
+	m := topologies[0].Members[0]
+	if m.Time["Unknown"] != 15*time.Second || m.Time["RSOther"] != 15*time.Second || m.Time["RSPrimary"] != 10*time.Second {
+		t.Errorf("member times %v", m.Time)
+	}
+}
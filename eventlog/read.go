@@ -0,0 +1,102 @@
+// Package eventlog reads events logs back: the JSON lines runs write now and
+// the "Writing Events &{...}" text lines older runs appended to events.log.
+package eventlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"test/monitor"
+	"time"
+)
+
+// legacyLine matches a line of the old events.log:
+//
+//	2024/06/17 12:03:23 Writing Events &{mongo-3:27017 ObjectID("666fd8b3e63cb158cabfbf25") Addr: mongo-3:27017, Type: Unknown Addr: mongo-3:27017, Type: RSSecondary, Average RTT: 985636}
+//
+// The time is when the line was written, which was once the run was over,
+// not when the server changed.
+var legacyLine = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}) Writing Events &\{(\S+) ObjectID\("([0-9a-f]+)"\) (Addr: .*)\}$`)
+
+// description matches the driver's description.Server String output.
+var description = regexp.MustCompile(`^Addr: [^,]+, Type: (\w+)(?:, Tag sets: .*?)?(?:, Average RTT: (\d+))?(?:, Last error: (.*))?$`)
+
+// Event is a server description change read from an events log.
+type Event struct {
+	monitor.ServerEvent
+	// Untimed is set for the lines of the old text format, whose Time is
+	// when the line was written rather than when the server changed.
+	Untimed bool
+}
+
+// Read returns the events of an events log in either format, in file order,
+// and how many lines it could not make sense of.
+func Read(r io.Reader) ([]Event, int, error) {
+	var events []Event
+	skipped := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		evt, err := parseLine(line)
+		if err != nil {
+			skipped++
+			continue
+		}
+		events = append(events, evt)
+	}
+	return events, skipped, scanner.Err()
+}
+
+func parseLine(line string) (Event, error) {
+	var evt Event
+	if strings.HasPrefix(line, "{") {
+		err := json.Unmarshal([]byte(line), &evt.ServerEvent)
+		return evt, err
+	}
+
+	m := legacyLine.FindStringSubmatch(line)
+	if m == nil {
+		return evt, fmt.Errorf("not an events log line: %q", line)
+	}
+	t, err := time.ParseInLocation("2006/01/02 15:04:05", m[1], time.Local)
+	if err != nil {
+		return evt, err
+	}
+	// Both descriptions start with "Addr: "; the new one is the second.
+	descs := m[4]
+	split := strings.Index(descs[len("Addr: "):], " Addr: ")
+	if split < 0 {
+		return evt, fmt.Errorf("missing new description: %q", line)
+	}
+	split += len("Addr: ")
+	previous := description.FindStringSubmatch(descs[:split])
+	current := description.FindStringSubmatch(descs[split+1:])
+	if previous == nil || current == nil {
+		return evt, fmt.Errorf("unreadable server description: %q", line)
+	}
+
+	evt = Event{ServerEvent: monitor.ServerEvent{
+		Time:         t,
+		Address:      m[2],
+		TopologyID:   m[3],
+		PreviousType: previous[1],
+		NewType:      current[1],
+		Error:        current[3],
+	}, Untimed: true}
+	if current[2] != "" {
+		rtt, err := strconv.ParseInt(current[2], 10, 64)
+		if err != nil {
+			return evt, err
+		}
+		evt.AvgRTTMs = float64(rtt) / float64(time.Millisecond)
+	}
+	return evt, nil
+}
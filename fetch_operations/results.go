@@ -0,0 +1,47 @@
+package fetch_operations
+
+import (
+	"context"
+	"log"
+	"test/config"
+	"test/monitor"
+	"test/results"
+	"test/stats"
+	"test/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func saveResults(cfg config.Config, phases *stats.Phases, mon *monitor.Monitor, label string, startedAt time.Time) {
+	phases.Log(label)
+	mon.Log()
+	if cfg.Options.ResultsDir == "" {
+		return
+	}
+	result := results.New(cfg, startedAt, phases)
+	result.AddMonitor(mon)
+	path, err := result.Save(cfg.Options.ResultsDir)
+	if err != nil {
+		log.Printf("Failed to save results: %v", err)
+		return
+	}
+	log.Printf("Results written to %s", path)
+}
+
+// startTracing returns the tracer of cfg.Tracing, nil when it is not
+// enabled. The returned func exports the remaining spans.
+func startTracing(cfg config.Config, label string) (*tracing.Tracer, func()) {
+	if !cfg.Tracing.Enabled() {
+		return nil, func() {}
+	}
+	tracer, err := tracing.New(context.TODO(), cfg.Tracing, attribute.String("mongobench.layout", label))
+	if err != nil {
+		log.Fatalf("Failed to start tracing: %v", err)
+	}
+	return tracer, func() {
+		if err := tracer.Close(context.TODO()); err != nil {
+			log.Printf("Failed to close tracing: %v", err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"log"
+	"test/config"
+	"test/queries"
+	"test/stats"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func connect(cfg config.Config) *mongo.Client {
+	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(cfg.URI))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return client
+}
+
+func runExplain(cfg config.Config) {
+	client := connect(cfg)
+	defer client.Disconnect(context.TODO())
+
+	phases := stats.NewPhases()
+	startExplain := time.Now()
+	queries.RunExplainOnCollection(client.Database(cfg.Database), context.TODO(), cfg.Collection)
+	phases.Get("explain").Since(startExplain)
+	phases.Log(cfg.Collection)
+}
+
+func runIndexes(cfg config.Config) {
+	client := connect(cfg)
+	defer client.Disconnect(context.TODO())
+
+	DB := client.Database(cfg.Database)
+	queries.GetAllIndexInCollection(DB, DB.Collection(cfg.Collection), context.TODO())
+}
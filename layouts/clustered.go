@@ -0,0 +1,57 @@
+package layouts
+
+import (
+	"context"
+	"test/config"
+	"test/queries"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const Clustered = "clustered"
+
+func init() {
+	Register(clustered{})
+	config.RegisterClusterKeyLayout(Clustered)
+}
+
+type clustered struct{}
+
+func (clustered) Name() string { return Clustered }
+
+func (clustered) Describe() string { return "Write into a collection clustered on _id" }
+
+func (clustered) Defaults(cfg *config.Config) {
+	cfg.Collection = "AdvertisementHistoryMDBClustered"
+	cfg.Iterations = []int{1000000}
+	cfg.Phases = []string{config.PhaseWrite}
+	cfg.Options.EventsLog = "events.jsonl"
+}
+
+func (clustered) Create(ctx context.Context, db *mongo.Database, name string) (*mongo.Collection, error) {
+	cio := bson.D{{Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}, {Key: "unique", Value: true}}
+	return createCollection(ctx, db, name, options.CreateCollection().SetClusteredIndex(cio), isClustered)
+}
+
+func (clustered) Write(ctx context.Context, collection *mongo.Collection, docs *queries.Documents) error {
+	_, err := queries.NewClusteredRepo(collection, docs.ClusterKey()).Insert(ctx, docs.NewAdvertisementHistoryClustered())
+	return err
+}
+
+func (clustered) NewDocument(docs *queries.Documents) interface{} {
+	return docs.NewAdvertisementHistoryClustered()
+}
+
+func (clustered) Read(ctx context.Context, collection *mongo.Collection, opts queries.ReadOptions) (queries.ReadStats, error) {
+	return read(ctx, queries.NewClusteredRepo(collection, opts.ClusterKey), opts, false)
+}
+
+func (clustered) ReadSorted(ctx context.Context, collection *mongo.Collection, opts queries.ReadOptions) (queries.ReadStats, error) {
+	return read(ctx, queries.NewClusteredRepo(collection, opts.ClusterKey), opts, true)
+}
+
+func (clustered) Cleanup(ctx context.Context, collection *mongo.Collection) error {
+	return drop(ctx, collection)
+}
@@ -0,0 +1,140 @@
+package layouts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"test/config"
+	"test/queries"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Layout is one way of storing AdvertisementHistory documents. The
+// benchmark runner drives every layout through the same phases, so a new
+// layout only has to say how its collection is created and which queries
+// it writes and reads with.
+type Layout interface {
+	Name() string
+	// Describe is a one line summary used in help output and reports.
+	Describe() string
+	// Defaults fills in the collection, iterations, phases and options the
+	// layout runs with unless a scenario or flag says otherwise.
+	Defaults(cfg *config.Config)
+	// Create creates the collection with the layout's options. An existing
+	// collection is reused if it is of the layout's kind, and an error
+	// otherwise.
+	Create(ctx context.Context, db *mongo.Database, name string) (*mongo.Collection, error)
+	// Write inserts the next document of docs. Write, Read and ReadSorted
+	// return their errors so that one failed operation does not end a run.
+	Write(ctx context.Context, collection *mongo.Collection, docs *queries.Documents) error
+	// NewDocument returns the next document of docs as Write would insert
+	// it, for the batched write paths.
+	NewDocument(docs *queries.Documents) interface{}
+	// Read and ReadSorted stream the documents opts selects, ReadSorted in
+	// _id order, and measure how fast they arrive.
+	Read(ctx context.Context, collection *mongo.Collection, opts queries.ReadOptions) (queries.ReadStats, error)
+	ReadSorted(ctx context.Context, collection *mongo.Collection, opts queries.ReadOptions) (queries.ReadStats, error)
+	// Cleanup drops the collection and everything written to it.
+	Cleanup(ctx context.Context, collection *mongo.Collection) error
+}
+
+var registry = map[string]Layout{}
+var names []string
+
+// Register makes a layout available to the CLI, scenario files and the
+// compare command. It is meant to be called from init and panics on a
+// duplicate name.
+func Register(layout Layout) {
+	name := layout.Name()
+	if _, ok := registry[name]; ok {
+		panic("layouts: Register called twice for " + name)
+	}
+	registry[name] = layout
+	names = append(names, name)
+	config.RegisterLayout(name, layout.Defaults)
+}
+
+func Get(name string) (Layout, bool) {
+	layout, ok := registry[name]
+	return layout, ok
+}
+
+// All returns the registered layouts in registration order.
+func All() []Layout {
+	all := make([]Layout, len(names))
+	for i, name := range names {
+		all[i] = registry[name]
+	}
+	return all
+}
+
+// namespaceExists is the server error code for creating a collection that
+// already exists.
+const namespaceExists = 48
+
+// createCollection creates the collection name with opts. A collection
+// that already exists is reused, provided check accepts it; otherwise a
+// layout would silently be benchmarked on another layout's collection.
+func createCollection(ctx context.Context, db *mongo.Database, name string, opts *options.CreateCollectionOptions, check func(spec *mongo.CollectionSpecification) error) (*mongo.Collection, error) {
+	err := db.CreateCollection(ctx, name, opts)
+	var cmdErr mongo.CommandError
+	if err != nil && !(errors.As(err, &cmdErr) && cmdErr.Code == namespaceExists) {
+		return nil, err
+	}
+	if err != nil {
+		if err := checkExisting(ctx, db, name, check); err != nil {
+			return nil, err
+		}
+	}
+	return db.Collection(name), nil
+}
+
+// checkExisting runs check on the specification of the collection name, if
+// there is one.
+func checkExisting(ctx context.Context, db *mongo.Database, name string, check func(spec *mongo.CollectionSpecification) error) error {
+	specs, err := db.ListCollectionSpecifications(ctx, bson.D{{Key: "name", Value: name}})
+	if err != nil {
+		return err
+	}
+	if len(specs) == 0 {
+		return nil
+	}
+	return check(specs[0])
+}
+
+func isTimeSeries(spec *mongo.CollectionSpecification) error {
+	if spec.Type != "timeseries" {
+		return fmt.Errorf("collection %s exists and is not a time-series collection, drop it or pick another", spec.Name)
+	}
+	return nil
+}
+
+func isClustered(spec *mongo.CollectionSpecification) error {
+	if spec.Type != "collection" || spec.Options.Lookup("clusteredIndex").Type == 0 {
+		return fmt.Errorf("collection %s exists and is not clustered, drop it or pick another", spec.Name)
+	}
+	return nil
+}
+
+func isRegular(spec *mongo.CollectionSpecification) error {
+	if spec.Type != "collection" || spec.Options.Lookup("clusteredIndex").Type != 0 {
+		return fmt.Errorf("collection %s exists and is clustered or time-series, drop it or pick another", spec.Name)
+	}
+	return nil
+}
+
+// read streams the documents of repo opts selects, in _id order when
+// sorted.
+func read[T any](ctx context.Context, repo queries.AdvertisementHistoryRepo[T], opts queries.ReadOptions, sorted bool) (queries.ReadStats, error) {
+	if sorted {
+		opts.Sort = []string{"_id"}
+	}
+	return repo.Read(ctx, opts)
+}
+
+func drop(ctx context.Context, collection *mongo.Collection) error {
+	return collection.Drop(ctx)
+}
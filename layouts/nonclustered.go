@@ -0,0 +1,61 @@
+package layouts
+
+import (
+	"context"
+	"test/config"
+	"test/queries"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const NonClustered = "nonclustered"
+
+func init() {
+	Register(nonClustered{})
+}
+
+type nonClustered struct{}
+
+func (nonClustered) Name() string { return NonClustered }
+
+func (nonClustered) Describe() string {
+	return "Write into a regular collection with secondary indexes"
+}
+
+func (nonClustered) Defaults(cfg *config.Config) {
+	cfg.Collection = "AdvertisementHistoryMDB"
+	cfg.Iterations = []int{1000000}
+	cfg.Phases = []string{config.PhaseWrite}
+	cfg.Options.CreateIndexes = true
+}
+
+// Create does not create anything up front: a regular collection is
+// created by the first insert. It only checks that an existing collection
+// is a regular one.
+func (nonClustered) Create(ctx context.Context, db *mongo.Database, name string) (*mongo.Collection, error) {
+	if err := checkExisting(ctx, db, name, isRegular); err != nil {
+		return nil, err
+	}
+	return db.Collection(name), nil
+}
+
+func (nonClustered) Write(ctx context.Context, collection *mongo.Collection, docs *queries.Documents) error {
+	_, err := queries.NewAdvertisementHistoryRepo[queries.AdvertisementHistoryMDB](collection).Insert(ctx, docs.NewAdvertisementHistory())
+	return err
+}
+
+func (nonClustered) NewDocument(docs *queries.Documents) interface{} {
+	return docs.NewAdvertisementHistory()
+}
+
+func (nonClustered) Read(ctx context.Context, collection *mongo.Collection, opts queries.ReadOptions) (queries.ReadStats, error) {
+	return read(ctx, queries.NewAdvertisementHistoryRepo[queries.AdvertisementHistoryMDB](collection), opts, false)
+}
+
+func (nonClustered) ReadSorted(ctx context.Context, collection *mongo.Collection, opts queries.ReadOptions) (queries.ReadStats, error) {
+	return read(ctx, queries.NewAdvertisementHistoryRepo[queries.AdvertisementHistoryMDB](collection), opts, true)
+}
+
+func (nonClustered) Cleanup(ctx context.Context, collection *mongo.Collection) error {
+	return drop(ctx, collection)
+}
@@ -0,0 +1,58 @@
+package layouts
+
+import (
+	"context"
+	"test/config"
+	"test/queries"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const Timeseries = "timeseries"
+
+func init() {
+	Register(timeseries{})
+}
+
+type timeseries struct{}
+
+func (timeseries) Name() string { return Timeseries }
+
+func (timeseries) Describe() string {
+	return "Write into a time-series collection and read it back"
+}
+
+func (timeseries) Defaults(cfg *config.Config) {
+	cfg.Collection = "AdvertisementHistoryMDBTimeSeries"
+	cfg.Iterations = []int{100}
+	cfg.Phases = []string{config.PhaseWrite, config.PhaseRead, config.PhaseReadSorted}
+	cfg.Options.Explain = true
+	cfg.Options.EventsLog = "events.jsonl"
+}
+
+func (timeseries) Create(ctx context.Context, db *mongo.Database, name string) (*mongo.Collection, error) {
+	tso := options.TimeSeries().SetTimeField("timeStamp").SetMetaField("deviceId").SetMetaField("reqRefNo")
+	return createCollection(ctx, db, name, options.CreateCollection().SetTimeSeriesOptions(tso), isTimeSeries)
+}
+
+func (timeseries) Write(ctx context.Context, collection *mongo.Collection, docs *queries.Documents) error {
+	_, err := queries.NewAdvertisementHistoryRepo[queries.AdvertisementHistoryMDB](collection).Insert(ctx, docs.NewAdvertisementHistory())
+	return err
+}
+
+func (timeseries) NewDocument(docs *queries.Documents) interface{} {
+	return docs.NewAdvertisementHistory()
+}
+
+func (timeseries) Read(ctx context.Context, collection *mongo.Collection, opts queries.ReadOptions) (queries.ReadStats, error) {
+	return read(ctx, queries.NewAdvertisementHistoryRepo[queries.AdvertisementHistoryMDB](collection), opts, false)
+}
+
+func (timeseries) ReadSorted(ctx context.Context, collection *mongo.Collection, opts queries.ReadOptions) (queries.ReadStats, error) {
+	return read(ctx, queries.NewAdvertisementHistoryRepo[queries.AdvertisementHistoryMDB](collection), opts, true)
+}
+
+func (timeseries) Cleanup(ctx context.Context, collection *mongo.Collection) error {
+	return drop(ctx, collection)
+}
@@ -1,24 +1,17 @@
 package main
 
 import (
-	// "log"
-	// "os"
-	// "test/clustered_test"
-	// "test/non_clustered_test"
-	"test/fetch_operations"
-
+	"errors"
+	"flag"
+	"log"
+	"os"
 )
 
 func main() {
-	// testType := os.Args
-	// if len(testType) >0 && testType[1] == "c" {
-	// 	log.Print("------ Starting clustered query ------")
-	// 	clustered_test.RunClustered()
-
-	// } else {
-	// 	log.Print("------ Starting non clustered query ------")
-	// 	non_clustered_test.RunNonClustered()
-	// }
-	fetch_operations.FetchClustered()
-
+	if err := run(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,105 @@
+package monitor
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// ServerEvent is a server description change as written to the events log,
+// one JSON object per line.
+type ServerEvent struct {
+	Time         time.Time `json:"time"`
+	RunID        string    `json:"runId"`
+	Address      string    `json:"address"`
+	TopologyID   string    `json:"topologyId"`
+	PreviousType string    `json:"previousType"`
+	NewType      string    `json:"newType"`
+	AvgRTTMs     float64   `json:"avgRttMs"`
+	SetName      string    `json:"setName,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+func newServerEvent(runID string, e *event.ServerDescriptionChangedEvent) ServerEvent {
+	evt := ServerEvent{
+		Time:         time.Now(),
+		RunID:        runID,
+		Address:      e.Address.String(),
+		TopologyID:   e.TopologyID.Hex(),
+		PreviousType: e.PreviousDescription.Kind.String(),
+		NewType:      e.NewDescription.Kind.String(),
+		AvgRTTMs:     float64(e.NewDescription.AverageRTT) / float64(time.Millisecond),
+		SetName:      e.NewDescription.SetName,
+	}
+	if e.NewDescription.LastError != nil {
+		evt.Error = e.NewDescription.LastError.Error()
+	}
+	return evt
+}
+
+// LogEvents appends every server description change to path as a JSON line
+// tagged with runID the moment the driver reports it, so the log survives a
+// run that crashes. It has to be called before connecting to catch the
+// initial discovery. The returned function stops logging and closes the
+// file.
+func (m *Monitor) LogEvents(path, runID string) (func() error, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.eventsLog = json.NewEncoder(file)
+	m.runID = runID
+	m.mu.Unlock()
+	return func() error {
+		m.mu.Lock()
+		m.eventsLog = nil
+		m.mu.Unlock()
+		return file.Close()
+	}, nil
+}
+
+func (m *Monitor) ServerMonitor() *event.ServerMonitor {
+	return &event.ServerMonitor{
+		ServerDescriptionChanged: func(e *event.ServerDescriptionChangedEvent) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			evt := newServerEvent(m.runID, e)
+			m.events = append(m.events, evt)
+			if m.eventsLog == nil {
+				return
+			}
+			if err := m.eventsLog.Encode(evt); err != nil {
+				log.Printf("Failed to write event: %v", err)
+			}
+		},
+	}
+}
+
+// ServerEvents returns the server description changes so far, oldest first.
+func (m *Monitor) ServerEvents() []ServerEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]ServerEvent(nil), m.events...)
+}
+
+// Servers returns the latest description change of every member, by
+// address.
+func (m *Monitor) Servers() []ServerEvent {
+	m.mu.Lock()
+	latest := map[string]ServerEvent{}
+	for _, evt := range m.events {
+		latest[evt.Address] = evt
+	}
+	m.mu.Unlock()
+	servers := make([]ServerEvent, 0, len(latest))
+	for _, evt := range latest {
+		servers = append(servers, evt)
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Address < servers[j].Address })
+	return servers
+}
@@ -0,0 +1,206 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Failure counts the commands that ran into the same error: the same command
+// on the same member with the same code and labels.
+type Failure struct {
+	Command string `json:"command"`
+	Host    string `json:"host"`
+	// Code is the server's code name, such as NotWritablePrimary or
+	// WriteConcernFailed, or NetworkTimeout or NetworkError for commands
+	// that never got a reply.
+	Code   string   `json:"code"`
+	Labels []string `json:"labels,omitempty"`
+	// Message is the first error message seen.
+	Message string    `json:"message"`
+	Count   int64     `json:"count"`
+	First   time.Time `json:"first"`
+	Last    time.Time `json:"last"`
+}
+
+func (f Failure) key() string {
+	return f.Command + "\x00" + f.Host + "\x00" + f.Code + "\x00" + strings.Join(f.Labels, ",")
+}
+
+// codeNames names the codes that show up in write errors, which carry a
+// code but no code name.
+var codeNames = map[int64]string{
+	50:    "MaxTimeMSExpired",
+	64:    "WriteConcernFailed",
+	91:    "ShutdownInProgress",
+	189:   "PrimarySteppedDown",
+	10107: "NotWritablePrimary",
+	11000: "DuplicateKey",
+	11600: "InterruptedAtShutdown",
+	11602: "InterruptedDueToReplStateChange",
+	13435: "NotPrimaryNoSecondaryOk",
+}
+
+// classify returns the code of a CommandFailedEvent.Failure. Server errors
+// read "(CodeName) message"; errors without a reply are network errors.
+func classify(failure string) string {
+	failure = strings.TrimSpace(failure)
+	if strings.HasPrefix(failure, "(") {
+		if end := strings.Index(failure, ")"); end > 1 {
+			return failure[1:end]
+		}
+	}
+	switch {
+	case strings.Contains(failure, "i/o timeout"),
+		strings.Contains(failure, "context deadline exceeded"),
+		strings.Contains(failure, "timed out"):
+		return "NetworkTimeout"
+	case strings.Contains(failure, "connection("):
+		return "NetworkError"
+	}
+	return "Unknown"
+}
+
+// errorLabels are the labels looked for on the errors operations return.
+var errorLabels = []string{
+	"NetworkError",
+	"NoWritesPerformed",
+	"RetryableWriteError",
+	"TransientTransactionError",
+	"UnknownTransactionCommitResult",
+}
+
+// classifyError returns the labels of the error an operation returned, and
+// its code when it is a network error, which has no code from the server.
+func classifyError(err error) (code string, labels []string) {
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		for _, label := range errorLabels {
+			if serverErr.HasErrorLabel(label) {
+				labels = append(labels, label)
+			}
+		}
+	}
+	switch {
+	case mongo.IsTimeout(err):
+		code = "NetworkTimeout"
+	case mongo.IsNetworkError(err):
+		code = "NetworkError"
+	}
+	return code, labels
+}
+
+type operationKey struct{}
+
+// operation holds the failed commands of an operation until it returns.
+type operation struct {
+	mu       sync.Mutex
+	monitor  *Monitor
+	failures []*Failure
+}
+
+// Track returns a context whose failed commands are recorded once done is
+// called with the error the operation returned. A CommandFailedEvent only
+// carries the error as text, without its labels, so the labels, and the
+// code of network errors, come from that error instead. It applies to the
+// last failed command, the one whose error the operation returned; the
+// attempts before a retry keep what their events said.
+func Track(ctx context.Context) (context.Context, func(err error)) {
+	op := &operation{}
+	return context.WithValue(ctx, operationKey{}, op), op.done
+}
+
+func (op *operation) add(m *Monitor, failure *Failure) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	op.monitor = m
+	op.failures = append(op.failures, failure)
+}
+
+// done records the failures held so far. It lets go of op.mu before it
+// takes the monitor's lock, which finished holds while it calls add.
+func (op *operation) done(err error) {
+	op.mu.Lock()
+	m, failures := op.monitor, op.failures
+	op.failures = nil
+	op.mu.Unlock()
+	if len(failures) == 0 {
+		return
+	}
+	if err != nil {
+		last := failures[len(failures)-1]
+		code, labels := classifyError(err)
+		if code != "" {
+			last.Code = code
+		}
+		last.Labels = mergeLabels(last.Labels, labels)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, failure := range failures {
+		m.record(failure)
+	}
+}
+
+// mergeLabels returns the labels of a and b, sorted and without duplicates.
+func mergeLabels(a, b []string) []string {
+	labels := append(append([]string(nil), a...), b...)
+	sort.Strings(labels)
+	merged := labels[:0]
+	for i, label := range labels {
+		if i == 0 || label != labels[i-1] {
+			merged = append(merged, label)
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// replyFailure reports the write concern error or first write error of a
+// reply the driver counted as succeeded. The application still sees those
+// as errors.
+func replyFailure(reply bson.Raw) (code string, labels []string, message string, failed bool) {
+	var doc bson.Raw
+	if v, err := reply.LookupErr("writeConcernError"); err == nil {
+		doc, failed = v.DocumentOK()
+	} else if v, err := reply.LookupErr("writeErrors"); err == nil {
+		if errs, ok := v.ArrayOK(); ok {
+			if values, err := errs.Values(); err == nil && len(values) > 0 {
+				doc, failed = values[0].DocumentOK()
+			}
+		}
+	}
+	if !failed {
+		return "", nil, "", false
+	}
+
+	if name, ok := doc.Lookup("codeName").StringValueOK(); ok {
+		code = name
+	} else if n, ok := doc.Lookup("code").AsInt64OK(); ok {
+		if code, ok = codeNames[n]; !ok {
+			code = fmt.Sprintf("code %d", n)
+		}
+	}
+	message, _ = doc.Lookup("errmsg").StringValueOK()
+	if v, err := reply.LookupErr("errorLabels"); err == nil {
+		if arr, ok := v.ArrayOK(); ok {
+			values, _ := arr.Values()
+			for _, label := range values {
+				if s, ok := label.StringValueOK(); ok {
+					labels = append(labels, s)
+				}
+			}
+		}
+	}
+	sort.Strings(labels)
+	return code, labels, message, true
+}
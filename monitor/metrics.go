@@ -0,0 +1,169 @@
+package monitor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"test/stats"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the latency
+// histograms served by WriteMetrics.
+var latencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// MetricsHandler serves WriteMetrics, labelled with layout, for Prometheus
+// to scrape.
+func (m *Monitor) MetricsHandler(layout string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := m.WriteMetrics(w, layout); err != nil {
+			log.Printf("Failed to write metrics: %v", err)
+		}
+	})
+}
+
+// WriteMetrics writes what the monitor saw so far in the Prometheus text
+// exposition format: commands and their latency, failed commands by member
+// and error code, connection pools and the latest description of every
+// member.
+func (m *Monitor) WriteMetrics(out io.Writer, layout string) error {
+	w := bufio.NewWriter(out)
+
+	family(w, "mongobench_commands_total", "counter", "Commands sent, by outcome.")
+	for _, name := range Commands {
+		cmd := m.Command(name)
+		fmt.Fprintf(w, "mongobench_commands_total%s %d\n", labels("layout", layout, "command", name, "outcome", "succeeded"), cmd.Succeeded)
+		fmt.Fprintf(w, "mongobench_commands_total%s %d\n", labels("layout", layout, "command", name, "outcome", "failed"), cmd.Failed)
+	}
+	family(w, "mongobench_command_duration_seconds", "histogram", "Round trip of succeeded and failed commands.")
+	for _, name := range Commands {
+		histogram(w, "mongobench_command_duration_seconds", m.Command(name).Latency, "layout", layout, "command", name)
+	}
+	family(w, "mongobench_command_host_total", "counter", "Commands served by each member.")
+	for _, name := range Commands {
+		cmd := m.Command(name)
+		for _, host := range sortedKeys(cmd.Hosts) {
+			fmt.Fprintf(w, "mongobench_command_host_total%s %d\n", labels("layout", layout, "command", name, "host", host), cmd.Hosts[host])
+		}
+	}
+
+	family(w, "mongobench_command_errors_total", "counter", "Failed commands by member and error code.")
+	for _, f := range m.Failures() {
+		fmt.Fprintf(w, "mongobench_command_errors_total%s %d\n",
+			labels("layout", layout, "command", f.Command, "host", f.Host, "code", f.Code, "labels", strings.Join(f.Labels, ",")), f.Count)
+	}
+
+	m.writePoolMetrics(w, layout)
+
+	servers := m.Servers()
+	family(w, "mongobench_server_info", "gauge", "Latest server type of every member, always 1.")
+	for _, s := range servers {
+		fmt.Fprintf(w, "mongobench_server_info%s 1\n", labels("layout", layout, "host", s.Address, "type", s.NewType, "set", s.SetName))
+	}
+	family(w, "mongobench_server_rtt_seconds", "gauge", "Average round trip time of every member's heartbeats.")
+	for _, s := range servers {
+		fmt.Fprintf(w, "mongobench_server_rtt_seconds%s %s\n", labels("layout", layout, "host", s.Address), float(s.AvgRTTMs/1000))
+	}
+	changes := map[string]int64{}
+	for _, evt := range m.ServerEvents() {
+		changes[evt.Address]++
+	}
+	family(w, "mongobench_server_description_changes_total", "counter", "Server description changes of every member.")
+	for _, host := range sortedKeys(changes) {
+		fmt.Fprintf(w, "mongobench_server_description_changes_total%s %d\n", labels("layout", layout, "host", host), changes[host])
+	}
+	return w.Flush()
+}
+
+func (m *Monitor) writePoolMetrics(w io.Writer, layout string) {
+	type gauges struct {
+		host        string
+		open, inUse int64
+	}
+	m.mu.Lock()
+	current := make([]gauges, 0, len(m.pools))
+	for host, p := range m.pools {
+		current = append(current, gauges{host, p.open, p.inUse})
+	}
+	m.mu.Unlock()
+	sort.Slice(current, func(i, j int) bool { return current[i].host < current[j].host })
+	pools := m.Pools()
+
+	family(w, "mongobench_pool_connections", "gauge", "Open connections to every member.")
+	for _, g := range current {
+		fmt.Fprintf(w, "mongobench_pool_connections%s %d\n", labels("layout", layout, "host", g.host), g.open)
+	}
+	family(w, "mongobench_pool_connections_in_use", "gauge", "Connections checked out to every member.")
+	for _, g := range current {
+		fmt.Fprintf(w, "mongobench_pool_connections_in_use%s %d\n", labels("layout", layout, "host", g.host), g.inUse)
+	}
+	for _, c := range []struct {
+		name, help string
+		value      func(Pool) int64
+	}{
+		{"mongobench_pool_connections_created_total", "Connections created to every member.", func(p Pool) int64 { return p.Created }},
+		{"mongobench_pool_connections_closed_total", "Connections closed to every member.", func(p Pool) int64 { return p.Closed }},
+		{"mongobench_pool_checkouts_total", "Successful connection checkouts.", func(p Pool) int64 { return p.CheckedOut }},
+		{"mongobench_pool_checkout_failures_total", "Failed connection checkouts.", func(p Pool) int64 { return p.CheckoutFailed }},
+		{"mongobench_pool_cleared_total", "Times the pool to a member was cleared.", func(p Pool) int64 { return p.Cleared }},
+	} {
+		family(w, c.name, "counter", c.help)
+		for _, p := range pools {
+			fmt.Fprintf(w, "%s%s %d\n", c.name, labels("layout", layout, "host", p.Host), c.value(p))
+		}
+	}
+	family(w, "mongobench_pool_checkout_wait_seconds", "histogram", "How long successful checkouts waited for a connection.")
+	for _, p := range pools {
+		histogram(w, "mongobench_pool_checkout_wait_seconds", p.CheckoutWait, "layout", layout, "host", p.Host)
+	}
+}
+
+func family(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// histogram writes h as the buckets, sum and count of the histogram name.
+func histogram(w io.Writer, name string, h *stats.Histogram, pairs ...string) {
+	for _, le := range latencyBuckets {
+		n := h.CountAtMost(time.Duration(le * float64(time.Second)))
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, labels(append(pairs, "le", float(le))...), n)
+	}
+	fmt.Fprintf(w, "%s_bucket%s %d\n", name, labels(append(pairs, "le", "+Inf")...), h.Count())
+	fmt.Fprintf(w, "%s_sum%s %s\n", name, labels(pairs...), float(h.Sum().Seconds()))
+	fmt.Fprintf(w, "%s_count%s %d\n", name, labels(pairs...), h.Count())
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels formats name/value pairs as a label set.
+func labels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, pairs[i], labelEscaper.Replace(pairs[i+1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func float(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys(counts map[string]int64) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
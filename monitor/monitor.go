@@ -0,0 +1,232 @@
+// Package monitor collects what the driver reports about the commands a
+// client sends and the servers it talks to: latency and outcome per command
+// name, which replica set member served each command, the errors commands
+// ran into, connection pool activity per member, and server description
+// changes.
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"test/stats"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// Commands are the command names the monitor records. Handshakes,
+// heartbeats and session housekeeping are left out.
+var Commands = []string{"insert", "find", "update", "delete", "aggregate", "getMore", "explain"}
+
+// Command is what the monitor saw of one command name.
+type Command struct {
+	Succeeded int64
+	Failed    int64
+	// Latency holds the round trip of every succeeded and failed command.
+	Latency *stats.Histogram
+	// Hosts counts the commands each member served, by host:port.
+	Hosts map[string]int64
+}
+
+// Monitor is meant to be shared by every client of a run. Its callbacks
+// are called from every worker goroutine and from the driver's own
+// heartbeat goroutines, so all fields are guarded by mu.
+type Monitor struct {
+	mu       sync.Mutex
+	commands map[string]*Command
+	failures map[string]*Failure
+	pools    map[string]*pool
+	// poolSamples is the time series SamplePools records.
+	poolSamples []PoolSample
+	// events are the server description changes so far. eventsLog also
+	// receives them, see LogEvents.
+	events    []ServerEvent
+	eventsLog *json.Encoder
+	runID     string
+}
+
+func New() *Monitor {
+	m := &Monitor{
+		commands: make(map[string]*Command),
+		failures: make(map[string]*Failure),
+		pools:    make(map[string]*pool),
+	}
+	for _, name := range Commands {
+		m.commands[name] = &Command{Latency: stats.NewHistogram(), Hosts: make(map[string]int64)}
+	}
+	return m
+}
+
+func (m *Monitor) CommandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			if attempts, ok := ctx.Value(attemptsKey{}).(*int32); ok {
+				atomic.AddInt32(attempts, 1)
+			}
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			if !m.tracks(evt.CommandName) {
+				return
+			}
+			var failure *Failure
+			if code, labels, message, failed := replyFailure(evt.Reply); failed {
+				failure = &Failure{Code: code, Labels: labels, Message: message}
+			}
+			m.finished(ctx, evt.CommandFinishedEvent, failure)
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			if !m.tracks(evt.CommandName) {
+				return
+			}
+			failure := &Failure{Code: classify(evt.Failure), Message: evt.Failure}
+			m.finished(ctx, evt.CommandFinishedEvent, failure)
+		},
+	}
+}
+
+type attemptsKey struct{}
+
+// CountAttempts returns a context that counts the commands sent on its
+// behalf, so a caller can tell that an operation the driver retried took
+// more than one attempt.
+func CountAttempts(ctx context.Context) (context.Context, *int32) {
+	attempts := new(int32)
+	return context.WithValue(ctx, attemptsKey{}, attempts), attempts
+}
+
+func (m *Monitor) tracks(command string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.commands[command]
+	return ok
+}
+
+// finished records a command that got a reply or failed. failure is nil
+// for commands that succeeded. The failures of an operation tracked by
+// Track are held back until it returns.
+func (m *Monitor) finished(ctx context.Context, evt event.CommandFinishedEvent, failure *Failure) {
+	now := time.Now()
+	host := Host(evt.ConnectionID)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cmd := m.commands[evt.CommandName]
+	cmd.Latency.Record(evt.Duration)
+	cmd.Hosts[host]++
+	if failure == nil {
+		cmd.Succeeded++
+		return
+	}
+	cmd.Failed++
+
+	failure.Command = evt.CommandName
+	failure.Host = host
+	failure.First = now
+	if op, ok := ctx.Value(operationKey{}).(*operation); ok {
+		op.add(m, failure)
+		return
+	}
+	m.record(failure)
+}
+
+// record counts failure, which happened at failure.First.
+func (m *Monitor) record(failure *Failure) {
+	at := failure.First
+	f, ok := m.failures[failure.key()]
+	if !ok {
+		f = failure
+		m.failures[f.key()] = f
+	}
+	f.Count++
+	f.Last = at
+}
+
+// Reset starts the command, failure and pool counts over and drops the
+// pool samples so far, for a run to leave its warmup out. The pool gauges
+// and the server description changes are kept.
+func (m *Monitor) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, name := range Commands {
+		m.commands[name] = &Command{Latency: stats.NewHistogram(), Hosts: make(map[string]int64)}
+	}
+	m.failures = make(map[string]*Failure)
+	for host, p := range m.pools {
+		p.total = Pool{Host: host, CheckoutWait: stats.NewHistogram()}
+		p.interval = PoolSample{}
+		p.maxWait = 0
+	}
+	m.poolSamples = nil
+}
+
+// Host returns the host:port of the member a driver connection ID such as
+// "127.0.10.1:27017[-12]" belongs to.
+func Host(connectionID string) string {
+	if i := strings.LastIndex(connectionID, "[-"); i >= 0 {
+		return connectionID[:i]
+	}
+	return connectionID
+}
+
+// Command returns a copy of what the monitor saw of the command name.
+func (m *Monitor) Command(name string) Command {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cmd, ok := m.commands[name]
+	if !ok {
+		return Command{Latency: stats.NewHistogram(), Hosts: map[string]int64{}}
+	}
+	c := Command{Succeeded: cmd.Succeeded, Failed: cmd.Failed, Latency: stats.NewHistogram(), Hosts: make(map[string]int64, len(cmd.Hosts))}
+	c.Latency.Merge(cmd.Latency)
+	for host, n := range cmd.Hosts {
+		c.Hosts[host] = n
+	}
+	return c
+}
+
+// Failures returns the errors commands ran into, most frequent first.
+func (m *Monitor) Failures() []Failure {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	failures := make([]Failure, 0, len(m.failures))
+	for _, f := range m.failures {
+		failures = append(failures, *f)
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		if failures[i].Count != failures[j].Count {
+			return failures[i].Count > failures[j].Count
+		}
+		return failures[i].key() < failures[j].key()
+	})
+	return failures
+}
+
+// Log logs the count, failures, latency and serving members of every
+// command that was sent at least once, the errors they ran into and the
+// activity of every connection pool.
+func (m *Monitor) Log() {
+	for _, name := range Commands {
+		cmd := m.Command(name)
+		if cmd.Succeeded+cmd.Failed == 0 {
+			continue
+		}
+		log.Printf("%s: %d succeeded, %d failed, latency %s", name, cmd.Succeeded, cmd.Failed, cmd.Latency.Summary())
+		hosts := make([]string, 0, len(cmd.Hosts))
+		for host := range cmd.Hosts {
+			hosts = append(hosts, host)
+		}
+		sort.Strings(hosts)
+		for _, host := range hosts {
+			log.Printf("  %s served %d", host, cmd.Hosts[host])
+		}
+	}
+	for _, f := range m.Failures() {
+		log.Printf("Failed %s on %s: %d x %s %v, first %s, last %s: %s",
+			f.Command, f.Host, f.Count, f.Code, f.Labels, f.First.Format(time.RFC3339), f.Last.Format(time.RFC3339), f.Message)
+	}
+	m.logPools()
+}
@@ -0,0 +1,62 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// TestTrackConcurrent finishes commands of tracked operations while other
+// operations return, which takes the monitor's and the operations' locks in
+// both orders. Run it with -race.
+func TestTrackConcurrent(t *testing.T) {
+	const operations, commands = 8, 5000
+	m := New()
+	evt := event.CommandFinishedEvent{CommandName: "insert", ConnectionID: "db1:27017[-3]", Duration: time.Millisecond}
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		var wg sync.WaitGroup
+		for i := 0; i < operations; i++ {
+			ctx, done := Track(context.Background())
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for k := 0; k < commands; k++ {
+					m.finished(ctx, evt, &Failure{Code: "NotWritablePrimary", Message: "not primary"})
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for k := 0; k < commands; k++ {
+					done(errors.New("not primary"))
+					m.Failures()
+				}
+			}()
+			// Records what the last done call left.
+			defer done(nil)
+		}
+		wg.Wait()
+	}()
+	select {
+	case <-finished:
+	case <-time.After(10 * time.Second):
+		t.Fatal("deadlocked")
+	}
+
+	var count int64
+	for _, f := range m.Failures() {
+		count += f.Count
+	}
+	if want := int64(operations * commands); count != want {
+		t.Errorf("recorded %d failures, want %d", count, want)
+	}
+	if cmd := m.Command("insert"); cmd.Failed != operations*commands || cmd.Hosts["db1:27017"] != operations*commands {
+		t.Errorf("insert: %d failed, hosts %v", cmd.Failed, cmd.Hosts)
+	}
+}
@@ -0,0 +1,154 @@
+package monitor
+
+import (
+	"context"
+	"log"
+	"sort"
+	"test/stats"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// Pool is what the pool monitor saw of the connection pool to one member.
+type Pool struct {
+	Host           string
+	Created        int64
+	Closed         int64
+	CheckedOut     int64
+	CheckoutFailed int64
+	Cleared        int64
+	// CheckoutWait holds how long every successful checkout waited for a
+	// connection.
+	CheckoutWait *stats.Histogram
+}
+
+// PoolSample is the state of the pool to one member at one point of a run,
+// with the counters covering the interval since the previous sample.
+type PoolSample struct {
+	Time time.Time `json:"time"`
+	Host string    `json:"host"`
+	// Open is the number of connections, InUse the number checked out.
+	Open           int64   `json:"open"`
+	InUse          int64   `json:"inUse"`
+	Created        int64   `json:"created"`
+	Closed         int64   `json:"closed"`
+	CheckedOut     int64   `json:"checkedOut"`
+	CheckoutFailed int64   `json:"checkoutFailed"`
+	Cleared        int64   `json:"cleared"`
+	MaxWaitMs      float64 `json:"maxCheckoutWaitMs"`
+}
+
+type pool struct {
+	total    Pool
+	open     int64
+	inUse    int64
+	interval PoolSample
+	maxWait  time.Duration
+}
+
+func (m *Monitor) PoolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(evt *event.PoolEvent) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			p, ok := m.pools[evt.Address]
+			if !ok {
+				p = &pool{total: Pool{Host: evt.Address, CheckoutWait: stats.NewHistogram()}}
+				m.pools[evt.Address] = p
+			}
+			switch evt.Type {
+			case event.ConnectionCreated:
+				p.total.Created++
+				p.interval.Created++
+				p.open++
+			case event.ConnectionClosed:
+				p.total.Closed++
+				p.interval.Closed++
+				p.open--
+			case event.GetSucceeded:
+				p.total.CheckedOut++
+				p.interval.CheckedOut++
+				p.inUse++
+				p.total.CheckoutWait.Record(evt.Duration)
+				if evt.Duration > p.maxWait {
+					p.maxWait = evt.Duration
+				}
+			case event.ConnectionReturned:
+				p.inUse--
+			case event.GetFailed:
+				p.total.CheckoutFailed++
+				p.interval.CheckoutFailed++
+			case event.PoolCleared:
+				p.total.Cleared++
+				p.interval.Cleared++
+			}
+		},
+	}
+}
+
+// Pools returns what the pool monitor saw of every member, by host.
+func (m *Monitor) Pools() []Pool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pools := make([]Pool, 0, len(m.pools))
+	for _, p := range m.pools {
+		copied := p.total
+		copied.CheckoutWait = stats.NewHistogram()
+		copied.CheckoutWait.Merge(p.total.CheckoutWait)
+		pools = append(pools, copied)
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].Host < pools[j].Host })
+	return pools
+}
+
+// SamplePools takes a PoolSample of every member each interval until ctx
+// is done.
+func (m *Monitor) SamplePools(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.samplePools(now)
+		}
+	}
+}
+
+func (m *Monitor) samplePools(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hosts := make([]string, 0, len(m.pools))
+	for host := range m.pools {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		p := m.pools[host]
+		sample := p.interval
+		sample.Time = now
+		sample.Host = host
+		sample.Open = p.open
+		sample.InUse = p.inUse
+		sample.MaxWaitMs = float64(p.maxWait) / float64(time.Millisecond)
+		m.poolSamples = append(m.poolSamples, sample)
+		p.interval = PoolSample{}
+		p.maxWait = 0
+	}
+}
+
+// PoolSamples returns the samples SamplePools took, oldest first.
+func (m *Monitor) PoolSamples() []PoolSample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]PoolSample(nil), m.poolSamples...)
+}
+
+func (m *Monitor) logPools() {
+	for _, p := range m.Pools() {
+		log.Printf("Pool %s: %d created, %d closed, %d checkouts, %d failed, %d cleared, checkout wait %s",
+			p.Host, p.Created, p.Closed, p.CheckedOut, p.CheckoutFailed, p.Cleared, p.CheckoutWait.Summary())
+	}
+}
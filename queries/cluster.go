@@ -0,0 +1,92 @@
+package queries
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// NewClusteredRepo returns a repository of collection, clustered on key, a
+// list of the fields of config.ClusterKeyFields, or on ObjectIDs when key
+// is empty.
+func NewClusteredRepo(collection *mongo.Collection, key []string) AdvertisementHistoryRepo[AdvertisementHistoryMDBClustered] {
+	return NewAdvertisementHistoryRepo[AdvertisementHistoryMDBClustered](collection).WithClusterKey(key)
+}
+
+// ClusterID returns the _id of a with the fields of key, in that order,
+// such as {deviceId: 18, timeStamp: 1700000000}. The documents of a
+// collection clustered on it are stored in that order, so those of a
+// device, or of a device over a time range, are next to each other.
+func (a AdvertisementHistory) ClusterID(key []string) bson.D {
+	id := make(bson.D, 0, len(key))
+	for _, field := range key {
+		id = append(id, bson.E{Key: field, Value: a.keyValue(field)})
+	}
+	return id
+}
+
+func (a AdvertisementHistory) keyValue(field string) interface{} {
+	switch field {
+	case "deviceId":
+		return a.DeviceID
+	case "timeStamp":
+		return a.TimeStamp
+	default:
+		return a.RequestRefNo
+	}
+}
+
+// keyRange returns the bounds on the _id of the documents f selects from a
+// collection clustered on key, or nil when f does not constrain the first
+// field of key. Like the index bounds on a compound index, the equality
+// conditions on the leading fields of key narrow the range, up to the
+// first field with a range condition or none.
+func (f Filter) keyRange(key []string) bson.D {
+	var lower, upper bson.D
+	for i, field := range key {
+		var value interface{}
+		switch field {
+		case "deviceId":
+			if f.DeviceID != 0 {
+				value = f.DeviceID
+			}
+		case "reqRefNo":
+			if f.ReqRefNo != "" {
+				value = f.ReqRefNo
+			}
+		case "timeStamp":
+			if f.From == 0 && f.To == 0 {
+				break
+			}
+			var from, to interface{} = primitive.MinKey{}, primitive.MaxKey{}
+			if f.From != 0 {
+				from = f.From
+			}
+			if f.To != 0 {
+				to = f.To
+			}
+			lower = append(lower, bson.E{Key: field, Value: from})
+			upper = append(upper, bson.E{Key: field, Value: to})
+			return bounds(lower, upper, key[i+1:])
+		}
+		if value == nil {
+			return bounds(lower, upper, key[i:])
+		}
+		lower = append(lower, bson.E{Key: field, Value: value})
+		upper = append(upper, bson.E{Key: field, Value: value})
+	}
+	return bounds(lower, upper, nil)
+}
+
+// bounds returns the condition on the _id between lower and upper, rest
+// being the fields of the key after them. An _id longer than upper sorts
+// after it, so upper is extended with the greatest value of the next field.
+func bounds(lower, upper bson.D, rest []string) bson.D {
+	if len(lower) == 0 {
+		return nil
+	}
+	if len(rest) > 0 {
+		upper = append(upper, bson.E{Key: rest[0], Value: primitive.MaxKey{}})
+	}
+	return bson.D{{Key: "$gte", Value: lower}, {Key: "$lte", Value: upper}}
+}
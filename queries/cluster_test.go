@@ -0,0 +1,94 @@
+package queries
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestKeyRange(t *testing.T) {
+	full := []string{"deviceId", "timeStamp", "reqRefNo"}
+	minKey, maxKey := primitive.MinKey{}, primitive.MaxKey{}
+	between := func(lower, upper bson.D) bson.D {
+		return bson.D{{Key: "$gte", Value: lower}, {Key: "$lte", Value: upper}}
+	}
+	tests := []struct {
+		name   string
+		key    []string
+		filter Filter
+		want   bson.D
+	}{
+		{"no key", nil, Filter{DeviceID: 18}, nil},
+		{"no filter", full, Filter{}, nil},
+		{"first field free", full, Filter{From: 100, To: 200}, nil},
+		{"device", full, Filter{DeviceID: 18},
+			between(bson.D{{Key: "deviceId", Value: int64(18)}},
+				bson.D{{Key: "deviceId", Value: int64(18)}, {Key: "timeStamp", Value: maxKey}})},
+		{"device and time range", full, Filter{DeviceID: 18, From: 100, To: 200},
+			between(bson.D{{Key: "deviceId", Value: int64(18)}, {Key: "timeStamp", Value: int64(100)}},
+				bson.D{{Key: "deviceId", Value: int64(18)}, {Key: "timeStamp", Value: int64(200)}, {Key: "reqRefNo", Value: maxKey}})},
+		{"device from", full, Filter{DeviceID: 18, From: 100},
+			between(bson.D{{Key: "deviceId", Value: int64(18)}, {Key: "timeStamp", Value: int64(100)}},
+				bson.D{{Key: "deviceId", Value: int64(18)}, {Key: "timeStamp", Value: maxKey}, {Key: "reqRefNo", Value: maxKey}})},
+		{"device to", full, Filter{DeviceID: 18, To: 200},
+			between(bson.D{{Key: "deviceId", Value: int64(18)}, {Key: "timeStamp", Value: minKey}},
+				bson.D{{Key: "deviceId", Value: int64(18)}, {Key: "timeStamp", Value: int64(200)}, {Key: "reqRefNo", Value: maxKey}})},
+		{"whole key", full, Filter{DeviceID: 18, From: 100, To: 100, ReqRefNo: "REQ1"},
+			between(bson.D{{Key: "deviceId", Value: int64(18)}, {Key: "timeStamp", Value: int64(100)}},
+				bson.D{{Key: "deviceId", Value: int64(18)}, {Key: "timeStamp", Value: int64(100)}, {Key: "reqRefNo", Value: maxKey}})},
+		{"equalities only", []string{"deviceId", "reqRefNo"}, Filter{DeviceID: 18, ReqRefNo: "REQ1"},
+			between(bson.D{{Key: "deviceId", Value: int64(18)}, {Key: "reqRefNo", Value: "REQ1"}},
+				bson.D{{Key: "deviceId", Value: int64(18)}, {Key: "reqRefNo", Value: "REQ1"}})},
+		{"time range first", []string{"timeStamp", "deviceId", "reqRefNo"}, Filter{DeviceID: 18, From: 100, To: 200},
+			between(bson.D{{Key: "timeStamp", Value: int64(100)}},
+				bson.D{{Key: "timeStamp", Value: int64(200)}, {Key: "deviceId", Value: maxKey}})},
+		{"field not in the filter", []string{"deviceId", "reqRefNo"}, Filter{DeviceID: 18, From: 100},
+			between(bson.D{{Key: "deviceId", Value: int64(18)}},
+				bson.D{{Key: "deviceId", Value: int64(18)}, {Key: "reqRefNo", Value: maxKey}})},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.keyRange(tt.key); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: keyRange(%v) = %v, want %v", tt.name, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestBounds(t *testing.T) {
+	lower := bson.D{{Key: "deviceId", Value: int64(1)}}
+	upper := bson.D{{Key: "deviceId", Value: int64(2)}}
+	tests := []struct {
+		name         string
+		lower, upper bson.D
+		rest         []string
+		want         bson.D
+	}{
+		{"empty", nil, nil, []string{"deviceId"}, nil},
+		{"last field", lower, upper, nil,
+			bson.D{{Key: "$gte", Value: lower}, {Key: "$lte", Value: upper}}},
+		{"more fields", lower, upper, []string{"timeStamp", "reqRefNo"},
+			bson.D{{Key: "$gte", Value: lower}, {Key: "$lte", Value: bson.D{{Key: "deviceId", Value: int64(2)}, {Key: "timeStamp", Value: primitive.MaxKey{}}}}}},
+	}
+	for _, tt := range tests {
+		if got := bounds(tt.lower, tt.upper, tt.rest); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: bounds = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClusterID(t *testing.T) {
+	a := AdvertisementHistory{DeviceID: 18, TimeStamp: 1700000000, RequestRefNo: "REQ42"}
+	tests := []struct {
+		key  []string
+		want bson.D
+	}{
+		{[]string{"deviceId", "timeStamp", "reqRefNo"}, bson.D{{Key: "deviceId", Value: int64(18)}, {Key: "timeStamp", Value: int64(1700000000)}, {Key: "reqRefNo", Value: "REQ42"}}},
+		{[]string{"timeStamp", "reqRefNo"}, bson.D{{Key: "timeStamp", Value: int64(1700000000)}, {Key: "reqRefNo", Value: "REQ42"}}},
+	}
+	for _, tt := range tests {
+		if got := a.ClusterID(tt.key); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ClusterID(%v) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
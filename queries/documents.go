@@ -0,0 +1,102 @@
+package queries
+
+import (
+	"sync"
+	"sync/atomic"
+	"test/datagen"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Documents generates the documents of a run, numbering them from 1, and
+// keeps the deviceIds of those bulk write batches wrote for their updates
+// to pick from. It is shared by every writer goroutine and safe for
+// concurrent use.
+type Documents struct {
+	generator *datagen.Generator
+	// key is what the _id of clustered documents is built from, a list of
+	// the fields of config.ClusterKeyFields, empty for ObjectIDs.
+	key []string
+	// counter numbers the generated documents, updates the updates of bulk
+	// write batches.
+	counter int64
+	updates int64
+
+	mu      sync.RWMutex
+	written []int64
+}
+
+// NewDocuments returns the documents g generates, clustered documents
+// getting an _id built from key.
+func NewDocuments(g *datagen.Generator, key []string) *Documents {
+	return &Documents{generator: g, key: key}
+}
+
+// Seed returns the seed of the generated documents.
+func (d *Documents) Seed() int64 {
+	return d.generator.Seed()
+}
+
+// ClusterKey returns the key the _id of clustered documents is built from.
+func (d *Documents) ClusterKey() []string {
+	return d.key
+}
+
+func (d *Documents) NewAdvertisementHistoryClustered() AdvertisementHistoryMDBClustered {
+	id, doc := d.next()
+	clustered := AdvertisementHistoryMDBClustered{AdvertisementHistory: doc}
+	switch {
+	case len(d.key) > 0:
+		clustered.ID = doc.ClusterID(d.key)
+	case !id.IsZero():
+		clustered.ID = id
+	}
+	return clustered
+}
+
+func (d *Documents) NewAdvertisementHistory() AdvertisementHistoryMDB {
+	id, doc := d.next()
+	return AdvertisementHistoryMDB{ID: id, AdvertisementHistory: doc}
+}
+
+// next generates the next document and returns it with its _id, which is
+// zero unless the data is seeded.
+func (d *Documents) next() (primitive.ObjectID, AdvertisementHistory) {
+	counter := atomic.AddInt64(&d.counter, 1)
+	fields := d.generator.Fields(counter)
+	return fields.ID, AdvertisementHistory{
+		RequestRefNo:       fields.RequestRefNo,
+		DeviceID:           fields.DeviceID,
+		TimeStamp:          fields.TimeStamp.Unix(),
+		ExpirationTime:     fields.TimeStamp.Add(24 * time.Hour).Unix(),
+		TMsgRecvByServer:   fields.TimeStamp.Add(1 * time.Hour).Unix(),
+		TMsgRecvFromDevice: fields.TimeStamp.Add(2 * time.Hour).Unix(),
+		AudioPlayed:        uint8(counter % 2),
+		Amount:             fields.Amount,
+		TransactionType:    fields.TransactionType,
+		RRN:                fields.RRN,
+	}
+}
+
+// AddWritten records the deviceIds of documents that were written, so
+// later updates can target them.
+func (d *Documents) AddWritten(devices ...int64) {
+	if len(devices) == 0 {
+		return
+	}
+	d.mu.Lock()
+	d.written = append(d.written, devices...)
+	d.mu.Unlock()
+}
+
+// NextUpdateDevice returns the deviceId the next update targets, the one of
+// a document AddWritten recorded, or 0 if there is none yet.
+func (d *Documents) NextUpdateDevice() int64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if len(d.written) == 0 {
+		return 0
+	}
+	return d.written[d.generator.Update(atomic.AddInt64(&d.updates, 1), int64(len(d.written)))]
+}
@@ -4,57 +4,48 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var globalCounter int64 = 0
-var maxRecord int64 = 1000000
+// AdvertisementHistory holds the fields every layout stores.
+type AdvertisementHistory struct {
+	RequestRefNo         string `bson:"reqRefNo" json:"reqRefNo" binding:"required"`
+	RRN                  string `bson:"rrn" json:"rrn"`
+	MerchantId           string `bson:"-" json:"mid"`
+	TerminalID           string `bson:"-" json:"tid,omitempty"`
+	MerchantCategoryCode string `bson:"-" json:"mcc"`
+	MerchantVPA          string `bson:"-" json:"meVpa"`
+	TransactionType      int8   `bson:"transactionType" json:"transactionType"`
+	TransactionMode      int8   `bson:"transactionMode" json:"transactionMode"`
+	Amount               string `bson:"txnAmt" json:"txnAmt" binding:"required"`
+	TransactionTimeStamp string `bson:"txnTimeStamp" json:"txnTimestamp"`
+	TimeStamp            int64  `bson:"timeStamp" json:"timeStamp"`
+	DeviceID             int64  `bson:"deviceId" json:"deviceId"`
+	ExpirationTime       int64  `bson:"expirationTime" json:"-"`
+	TMsgRecvByServer     int64  `bson:"tMsgRecvByServer" json:"tMsgRecvByServer"`
+	TMsgRecvFromDevice   int64  `bson:"tMsgRecvFromDev" json:"tMsgRecvFromDev"`
+	AudioPlayed          uint8  `bson:"audioPlayed" json:"audioPlayed"`
+	MessageId            int64  `gorm:"-" json:"id"`
+}
+
+// Device returns the deviceId of a.
+func (a AdvertisementHistory) Device() int64 {
+	return a.DeviceID
+}
 
 type AdvertisementHistoryMDB struct {
 	ID                   primitive.ObjectID `bson:"_id,omitempty"`
-	RequestRefNo         string             `bson:"reqRefNo" json:"reqRefNo" binding:"required"`
-	RRN                  string             `bson:"rrn" json:"rrn"`
-	MerchantId           string             `bson:"-" json:"mid"`
-	TerminalID           string             `bson:"-" json:"tid,omitempty"`
-	MerchantCategoryCode string             `bson:"-" json:"mcc"`
-	MerchantVPA          string             `bson:"-" json:"meVpa"`
-	TransactionType      int8               `bson:"transactionType" json:"transactionType"`
-	TransactionMode      int8               `bson:"transactionMode" json:"transactionMode"`
-	Amount               string             `bson:"txnAmt" json:"txnAmt" binding:"required"`
-	TransactionTimeStamp string             `bson:"txnTimeStamp" json:"txnTimestamp"`
-	TimeStamp            int64              `bson:"timeStamp" json:"timeStamp"`
-	DeviceID             int64              `bson:"deviceId" json:"deviceId"`
-	ExpirationTime       int64              `bson:"expirationTime" json:"-"`
-	TMsgRecvByServer     int64              `bson:"tMsgRecvByServer" json:"tMsgRecvByServer"`
-	TMsgRecvFromDevice   int64              `bson:"tMsgRecvFromDev" json:"tMsgRecvFromDev"`
-	AudioPlayed          uint8              `bson:"audioPlayed" json:"audioPlayed"`
-	MessageId            int64              `gorm:"-" json:"id"`
+	AdvertisementHistory `bson:",inline"`
 }
 
+// AdvertisementHistoryMDBClustered is stored in a collection clustered on
+// _id, an ObjectID or, with a cluster key, the document ClusterID returns.
 type AdvertisementHistoryMDBClustered struct {
-	ID                   primitive.ObjectID `bson:"_id,omitempty"`
-	RequestRefNo         string             `bson:"reqRefNo" json:"reqRefNo" binding:"required"`
-	RRN                  string             `bson:"rrn" json:"rrn"`
-	MerchantId           string             `bson:"-" json:"mid"`
-	TerminalID           string             `bson:"-" json:"tid,omitempty"`
-	MerchantCategoryCode string             `bson:"-" json:"mcc"`
-	MerchantVPA          string             `bson:"-" json:"meVpa"`
-	TransactionType      int8               `bson:"transactionType" json:"transactionType"`
-	TransactionMode      int8               `bson:"transactionMode" json:"transactionMode"`
-	Amount               string             `bson:"txnAmt" json:"txnAmt" binding:"required"`
-	TransactionTimeStamp string             `bson:"txnTimeStamp" json:"txnTimestamp"`
-	TimeStamp            int64              `bson:"timeStamp" json:"timeStamp"`
-	DeviceID             int64              `bson:"deviceId" json:"deviceId"`
-	ExpirationTime       int64              `bson:"expirationTime" json:"-"`
-	TMsgRecvByServer     int64              `bson:"tMsgRecvByServer" json:"tMsgRecvByServer"`
-	TMsgRecvFromDevice   int64              `bson:"tMsgRecvFromDev" json:"tMsgRecvFromDev"`
-	AudioPlayed          uint8              `bson:"audioPlayed" json:"audioPlayed"`
-	MessageId            int64              `gorm:"-" json:"id"`
+	ID                   interface{} `bson:"_id,omitempty"`
+	AdvertisementHistory `bson:",inline"`
 }
 
 type MetaData struct {
@@ -76,13 +67,7 @@ type AdvertisementHistoryMDBTimeSeries struct {
 }
 
 func CreateIndex(collection *mongo.Collection, ctx context.Context) {
-	indexes := []mongo.IndexModel{
-		{Keys: bson.D{{"deviceId", 1}, {"audioPlayed", 1}}},
-		{Keys: bson.D{{"deviceId", 1}, {"tMsgRecvByServer", 1}}},
-		{Keys: bson.D{{"reqRefNo", 1}}},
-		{Keys: bson.D{{"tMsgRecvByServer", 1}, {"audioPlayed", 1}}},
-	}
-	names, err := collection.Indexes().CreateMany(ctx, indexes)
+	names, err := NewAdvertisementHistoryRepo[AdvertisementHistoryMDB](collection).CreateIndexes(ctx)
 	if err != nil {
 		log.Fatalf("Failed to create indexes: %v", err)
 	}
@@ -91,348 +76,53 @@ func CreateIndex(collection *mongo.Collection, ctx context.Context) {
 	}
 }
 
-func dropAllIndex(collection *mongo.Collection, ctx context.Context) {
-	indexes, err := collection.Indexes().DropAll(ctx)
-	if err != nil {
-		log.Fatalf("Failed to drop indexes: %v", err)
-	}
-	fmt.Println("Dropped Indexes: " + string(indexes))
-}
-
-func MongoWriteClustered(collection *mongo.Collection, ctx context.Context) {
-	globalCounter++
-	doc := AdvertisementHistoryMDBClustered{
-		RequestRefNo:       fmt.Sprintf("REQ%d", globalCounter),
-		DeviceID:           int64(globalCounter),
-		TimeStamp:          time.Now().Unix(),
-		ExpirationTime:     time.Now().Add(24 * time.Hour).Unix(),
-		TMsgRecvByServer:   time.Now().Add(1 * time.Hour).Unix(),
-		TMsgRecvFromDevice: time.Now().Add(2 * time.Hour).Unix(),
-		AudioPlayed:        uint8(globalCounter % 2),
-		Amount:             "4000.00",
-		TransactionType:    3,
-		RRN:                "ddff",
-	}
-
-	_, err := collection.InsertOne(ctx, doc)
-	if err != nil {
-		log.Fatalf("Failed to insert document: %v", err)
-	}
-}
-
-func MongoReadClustered(collection *mongo.Collection, ctx context.Context) {
-	var dataAdv []AdvertisementHistoryMDBClustered
-	opt := options.Find().SetLimit(maxRecord)
-	dataAdvCursor, err := collection.Find(ctx, bson.D{}, opt)
-	if err != nil {
-		log.Fatalf("Failed to find documents: %v", err)
-	}
-	defer dataAdvCursor.Close(ctx)
-
-	if err = dataAdvCursor.All(ctx, &dataAdv); err != nil {
-		log.Fatalf("Failed to decode documents: %v", err)
-	}
-}
-
-func MongoWrite(collection *mongo.Collection, ctx context.Context) {
-	globalCounter++
-	doc := AdvertisementHistoryMDB{
-		RequestRefNo:       fmt.Sprintf("REQ%d", globalCounter),
-		DeviceID:           int64(globalCounter),
-		TimeStamp:          time.Now().Unix(),
-		ExpirationTime:     time.Now().Add(24 * time.Hour).Unix(),
-		TMsgRecvByServer:   time.Now().Add(1 * time.Hour).Unix(),
-		TMsgRecvFromDevice: time.Now().Add(2 * time.Hour).Unix(),
-		AudioPlayed:        uint8(globalCounter % 2),
-		Amount:             "4000.00",
-		TransactionType:    3,
-		RRN:                "ddff",
-	}
-
-	_, err := collection.InsertOne(ctx, doc)
-	if err != nil {
-		log.Fatalf("Failed to insert document: %v", err)
-	}
-}
-
-func MongoTimeSeries(collection *mongo.Collection, ctx context.Context) {
-	var documents []interface{}
-
-	for i := 0; i < 10; i++ {
-		globalCounter++
-		doc := AdvertisementHistoryMDBTimeSeries{
-			AdvertisementID:    int64(globalCounter + 1000),
-			TimeStamp:          time.Now().Unix(),
-			ExpirationTime:     time.Now().Add(24 * time.Hour).Unix(),
-			TMsgRecvByServer:   time.Now().Add(1 * time.Hour).Unix(),
-			TMsgRecvFromDevice: time.Now().Add(2 * time.Hour).Unix(),
-			AudioPlayed:        uint8(globalCounter % 2),
-			CreatedBy:          int64(globalCounter + 100),
-			Meta: MetaData{
-				DeviceID:     int64(globalCounter),
-				RequestRefNo: fmt.Sprintf("REQ%d", i),
-			},
-		}
-		documents = append(documents, doc)
-	}
-
-	_, err := collection.InsertMany(ctx, documents)
-	if err != nil {
-		log.Fatalf("Failed to insert documents: %v", err)
-	}
-
-	fmt.Println("Documents inserted successfully")
-}
-
-func MongoRead(collection *mongo.Collection, ctx context.Context) {
-	var dataAdv []AdvertisementHistoryMDB
-	opt := options.Find().SetLimit(maxRecord)
-	dataAdvCursor, err := collection.Find(ctx, bson.D{}, opt)
-	if err != nil {
-		log.Fatalf("Failed to find documents: %v", err)
-	}
-	defer dataAdvCursor.Close(ctx)
-
-	if err = dataAdvCursor.All(ctx, &dataAdv); err != nil {
-		log.Fatalf("Failed to decode documents: %v", err)
-	}
-}
-
-func MongoReadSortByID(collection *mongo.Collection, ctx context.Context) {
-	var dataAdv []AdvertisementHistoryMDB
-	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
-	opts.SetLimit(maxRecord)
-	dataAdvCursor, err := collection.Find(ctx, bson.D{}, opts)
-	if err != nil {
-		log.Fatalf("Failed to find documents: %v", err)
-	}
-	defer dataAdvCursor.Close(ctx)
-
-	if err = dataAdvCursor.All(ctx, &dataAdv); err != nil {
-		log.Fatalf("Failed to decode documents: %v", err)
-	}
-}
-
-func MongoReadSortByIDClustered(collection *mongo.Collection, ctx context.Context) {
-	var dataAdv []AdvertisementHistoryMDBClustered
-	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
-	opts.SetLimit(maxRecord)
-	dataAdvCursor, err := collection.Find(ctx, bson.D{}, opts)
-	if err != nil {
-		log.Fatalf("Failed to find documents: %v", err)
-	}
-	defer dataAdvCursor.Close(ctx)
-
-	if err = dataAdvCursor.All(ctx, &dataAdv); err != nil {
-		log.Fatalf("Failed to decode documents: %v", err)
-	}
-}
-
-func MongoReadSortByDeviceIdIndex(collection *mongo.Collection, ctx context.Context) {
-	var dataAdv []AdvertisementHistoryMDB
-	opts := options.Find().SetSort(bson.D{{Key: "deviceId", Value: 1}})
-	opts.SetLimit(maxRecord)
-	dataAdvCursor, err := collection.Find(ctx, bson.D{}, opts)
-	if err != nil {
-		log.Fatalf("Failed to find documents: %v", err)
-	}
-	defer dataAdvCursor.Close(ctx)
-
-	if err = dataAdvCursor.All(ctx, &dataAdv); err != nil {
-		log.Fatalf("Failed to decode documents: %v", err)
-	}
-}
-
-func MongoReadSortByIndexClustered(collection *mongo.Collection, ctx context.Context) {
-	var dataAdv []AdvertisementHistoryMDB
-	opts := options.Find().SetSort(bson.D{{Key: "deviceId", Value: 1}})
-	opts.SetLimit(maxRecord)
-	dataAdvCursor, err := collection.Find(ctx, bson.D{}, opts)
-	if err != nil {
-		log.Fatalf("Failed to find documents: %v", err)
-	}
-	defer dataAdvCursor.Close(ctx)
-
-	if err = dataAdvCursor.All(ctx, &dataAdv); err != nil {
-		log.Fatalf("Failed to decode documents: %v", err)
-	}
+// NewAudioPlayedUpdate returns a bulk write model marking the audio of
+// deviceId's advertisement as played.
+func NewAudioPlayedUpdate(deviceId int64) mongo.WriteModel {
+	return mongo.NewUpdateOneModel().
+		SetFilter(bson.D{{Key: "deviceId", Value: deviceId}}).
+		SetUpdate(bson.D{{Key: "$set", Value: bson.D{{Key: "audioPlayed", Value: 1}}}})
 }
 
 func GetAllIndexInCollection(db *mongo.Database, collection *mongo.Collection, ctx context.Context) {
-	command := bson.D{{Key: "listIndexes", Value: "AdvertisementHistoryMDB"}}
-	var result bson.M
-	err := db.RunCommand(context.TODO(), command).Decode(&result)
+	indexes, err := NewAdvertisementHistoryRepo[bson.M](collection).ListIndexes(ctx)
 	fmt.Println("GetAllIndexInCollection")
 	if err != nil {
 		log.Fatalf("Failed to get all indexes: %v", err)
 	}
-	fmt.Println("All Indexes", result)
+	fmt.Println("All Indexes", indexes)
 }
 
-func GetAllIndexInCollectionCluster(db *mongo.Database, collection *mongo.Collection, ctx context.Context) {
-	command := bson.D{{Key: "listIndexes", Value: "AdvertisementHistoryMDBClustered"}}
-	var result bson.M
-	err := db.RunCommand(context.TODO(), command).Decode(&result)
-	fmt.Println("GetAllIndexInCollection")
-	if err != nil {
-		log.Fatalf("Failed to get all indexes: %v", err)
-	}
-	fmt.Println("All Indexes", result)
-}
-
-
 func MongoReadByDevice(collection *mongo.Collection, ctx context.Context, deviceId int64) {
-	var dataAdv []AdvertisementHistoryMDB
-	filter := bson.D{
-		{"deviceId", deviceId},
-	}
-
-	dataAdvCursor, err := collection.Find(ctx, filter)
+	dataAdv, err := NewAdvertisementHistoryRepo[AdvertisementHistoryMDB](collection).Find(ctx, Filter{DeviceID: deviceId}, FindOptions{})
 	if err != nil {
 		log.Fatalf("Failed to find documents: %v", err)
 	}
-	defer dataAdvCursor.Close(ctx)
-
-	if err = dataAdvCursor.All(ctx, &dataAdv); err != nil {
-		log.Fatalf("Failed to decode documents: %v", err)
-	}
 	fmt.Printf("Number of documents returned: %d\n", len(dataAdv))
 }
 
-func MongoReadClusteredByDevice(collection *mongo.Collection, ctx context.Context, deviceId int64) {
-	var dataAdv []AdvertisementHistoryMDBClustered
-	filter := bson.D{
-		{"deviceId", deviceId},
-
-	}
-	dataAdvCursor, err := collection.Find(ctx, filter)
-	if err != nil {
-		log.Fatalf("Failed to find documents: %v", err)
-	}
-	defer dataAdvCursor.Close(ctx)
-
-	if err = dataAdvCursor.All(ctx, &dataAdv); err != nil {
-		log.Fatalf("Failed to decode documents: %v", err)
-	}
-}
-
-func MongoReadByDeviceAndTimestamp(collection *mongo.Collection, ctx context.Context, deviceId int64, startTime, endTime int64) {
-	var dataAdv []AdvertisementHistoryMDB
-	filter := bson.D{
-		{"deviceId", deviceId},
-		{"timeStamp", bson.D{
-			{"$gte", startTime},
-			{"$lte", endTime},
-		}},
-	}
-
-	dataAdvCursor, err := collection.Find(ctx, filter)
-	if err != nil {
-		log.Fatalf("Failed to find documents: %v", err)
-	}
-	defer dataAdvCursor.Close(ctx)
-
-	if err = dataAdvCursor.All(ctx, &dataAdv); err != nil {
-		log.Fatalf("Failed to decode documents: %v", err)
-	}
-}
-
-func MongoReadClusteredByDeviceAndTimestamp(collection *mongo.Collection, ctx context.Context, deviceId int64, startTime, endTime int64) {
-	var dataAdv []AdvertisementHistoryMDBClustered
-	filter := bson.D{
-		{"deviceId", deviceId},
-		{"timeStamp", bson.D{
-			{"$gte", startTime},
-			{"$lte", endTime},
-		}},
-	}
-	dataAdvCursor, err := collection.Find(ctx, filter)
-	if err != nil {
-		log.Fatalf("Failed to find documents: %v", err)
-	}
-	defer dataAdvCursor.Close(ctx)
-
-	if err = dataAdvCursor.All(ctx, &dataAdv); err != nil {
-		log.Fatalf("Failed to decode documents: %v", err)
-	}
-}
-
-
 func MongoUpdateDeviceId(collection *mongo.Collection, ctx context.Context, updateFields bson.D) {
-
-
-	findOptions := options.Find().SetLimit(100000).SetProjection(bson.D{{"_id", 1}})
-	cursor, err := collection.Find(ctx,bson.D{},findOptions)
-	if err != nil {
-		log.Fatalf("Failed to find documents: %v", err)
-	}
-	defer cursor.Close(ctx)
-
-	var ids []interface{}
-	for cursor.Next(ctx) {
-		var doc bson.M
-		if err = cursor.Decode(&doc); err != nil {
-			log.Fatalf("Failed to decode document: %v", err)
-		}
-		ids = append(ids, doc["_id"])
-	}
-
-	if len(ids) == 0 {
-		fmt.Println("No documents found to update.")
-		return
-	}
-
-	updateFilter := bson.D{{"_id", bson.D{{"$in", ids}}}}
-	update := bson.D{
-		{"$set", updateFields},
-	}
-
-	result, err := collection.UpdateMany(ctx, updateFilter, update)
+	matched, modified, err := NewAdvertisementHistoryRepo[AdvertisementHistoryMDB](collection).UpdateFirst(ctx, Filter{}, 100000, updateFields)
 	if err != nil {
 		log.Fatalf("Failed to update documents: %v", err)
 	}
-	fmt.Printf("Matched %v documents and updated %v documents.\n", result.MatchedCount, result.ModifiedCount)
+	fmt.Printf("Matched %v documents and updated %v documents.\n", matched, modified)
 }
 
 func MongoUpdateaudioPlayed(collection *mongo.Collection, ctx context.Context, deviceId int64, tMsgRecvByServer int64, updateFields bson.D) {
-	filter := bson.D{
-		{"deviceId", deviceId},
-		{"tMsgRecvByServer", tMsgRecvByServer},
-	}
-
-	update := bson.D{
-		{"$set", updateFields},
-	}
-
-	result, err := collection.UpdateMany(ctx, filter, update)
-	if err != nil {
-		log.Fatalf("Failed to update documents: %v", err)
-	}
-	fmt.Printf("Matched %v documents and updated %v documents.\n", result.MatchedCount, result.ModifiedCount)
-}
-
-func MongoUpdateClusteredByDeviceAndTimestamp(collection *mongo.Collection, ctx context.Context, deviceId int64, startTime, endTime int64, updateFields bson.D) {
-	filter := bson.D{
-		{"deviceId", deviceId},
-		{"timeStamp", bson.D{
-			{"$gte", startTime},
-			{"$lte", endTime},
-		}},
-	}
-	update := bson.D{
-		{"$set", updateFields},
-	}
-	result, err := collection.UpdateMany(ctx, filter, update)
+	matched, modified, err := NewAdvertisementHistoryRepo[AdvertisementHistoryMDB](collection).Update(ctx, Filter{DeviceID: deviceId, TMsgRecvByServer: tMsgRecvByServer}, updateFields)
 	if err != nil {
 		log.Fatalf("Failed to update documents: %v", err)
 	}
-	fmt.Printf("Matched %v documents and updated %v documents.\n", result.MatchedCount, result.ModifiedCount)
+	fmt.Printf("Matched %v documents and updated %v documents.\n", matched, modified)
 }
 
 func GetIndexStatus(collection *mongo.Collection, ctx context.Context) {
 	aggCommand := bson.D{
 		{Key: "$indexStats", Value: bson.D{}},
 	}
+	ctx, span := startSpan(ctx, "aggregate", collection, nil)
+	defer span.End()
 	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{aggCommand})
 	fmt.Println("GetIndexStatus")
 	if err != nil {
@@ -467,18 +157,79 @@ func GetServerStatus(db *mongo.Database, ctx context.Context) {
 	fmt.Printf("Server status: %+v\n", result)
 }
 
-func RunExplainOnCollection(db *mongo.Database, ctx context.Context) {
-	var result bson.M
-	// Create the explain command
-	command := bson.D{
-		{Key: "explain", Value: bson.D{
-			{Key: "find", Value: "AdvertisementHistoryMDB"},
-			{Key: "sort", Value: bson.D{{Key: "deviceId", Value: 1}}},
-		}},
-		{Key: "verbosity", Value: "executionStats"},
-	}
-
-	err := db.RunCommand(ctx, command).Decode(&result)
+// ServerStatus is the part of serverStatus that shows how busy a member
+// is. Counters are totals since the member started.
+type ServerStatus struct {
+	Opcounters struct {
+		Insert  int64 `bson:"insert"`
+		Query   int64 `bson:"query"`
+		Update  int64 `bson:"update"`
+		Delete  int64 `bson:"delete"`
+		GetMore int64 `bson:"getmore"`
+		Command int64 `bson:"command"`
+	} `bson:"opcounters"`
+	Network struct {
+		BytesIn     int64 `bson:"bytesIn"`
+		BytesOut    int64 `bson:"bytesOut"`
+		NumRequests int64 `bson:"numRequests"`
+	} `bson:"network"`
+	Metrics struct {
+		Document struct {
+			Deleted  int64 `bson:"deleted"`
+			Inserted int64 `bson:"inserted"`
+			Returned int64 `bson:"returned"`
+			Updated  int64 `bson:"updated"`
+		} `bson:"document"`
+	} `bson:"metrics"`
+	WiredTiger struct {
+		Cache struct {
+			MaxBytes             int64 `bson:"maximum bytes configured"`
+			Bytes                int64 `bson:"bytes currently in the cache"`
+			DirtyBytes           int64 `bson:"tracked dirty bytes in the cache"`
+			BytesRead            int64 `bson:"bytes read into cache"`
+			BytesWritten         int64 `bson:"bytes written from cache"`
+			ModifiedEvicted      int64 `bson:"modified pages evicted"`
+			UnmodifiedEvicted    int64 `bson:"unmodified pages evicted"`
+			ApplicationEvictions int64 `bson:"pages evicted by application threads"`
+		} `bson:"cache"`
+		// ConcurrentTransactions holds the tickets before MongoDB 7.0.
+		ConcurrentTransactions Tickets `bson:"concurrentTransactions"`
+	} `bson:"wiredTiger"`
+	// Queues holds the tickets from MongoDB 7.0 on.
+	Queues struct {
+		Execution Tickets `bson:"execution"`
+	} `bson:"queues"`
+}
+
+// Tickets are the storage engine's read and write tickets.
+type Tickets struct {
+	Read  TicketPool `bson:"read"`
+	Write TicketPool `bson:"write"`
+}
+
+type TicketPool struct {
+	Out       int64 `bson:"out"`
+	Available int64 `bson:"available"`
+}
+
+// Tickets returns the tickets of whichever section the member reported.
+func (s ServerStatus) Tickets() Tickets {
+	if s.Queues.Execution != (Tickets{}) {
+		return s.Queues.Execution
+	}
+	return s.WiredTiger.ConcurrentTransactions
+}
+
+// ReadServerStatus runs serverStatus on db's client and returns the part
+// of it ServerStatus keeps.
+func ReadServerStatus(db *mongo.Database, ctx context.Context) (ServerStatus, error) {
+	var status ServerStatus
+	err := db.RunCommand(ctx, bson.D{{Key: "serverStatus", Value: 1}}).Decode(&status)
+	return status, err
+}
+
+func RunExplainOnCollection(db *mongo.Database, ctx context.Context, collectionName string) {
+	result, err := NewAdvertisementHistoryRepo[bson.M](db.Collection(collectionName)).Explain(ctx, Filter{}, ExplainOptions)
 	fmt.Println("RunExplainOnCollection")
 	if err != nil {
 		log.Fatalf("Failed to run explain: %v", err)
@@ -486,21 +237,12 @@ func RunExplainOnCollection(db *mongo.Database, ctx context.Context) {
 	fmt.Printf("Explain result: %+v\n", result)
 }
 
-func RunExplainOnCollectionCluster(db *mongo.Database, ctx context.Context) {
-	var result bson.M
-	// Create the explain command
-	command := bson.D{
-		{Key: "explain", Value: bson.D{
-			{Key: "find", Value: "AdvertisementHistoryMDBClustered"},
-			{Key: "sort", Value: bson.D{{Key: "deviceId", Value: 1}}},
-		}},
-		{Key: "verbosity", Value: "executionStats"},
-	}
+// ExplainOptions are those of the find RunExplainOnCollection explains.
+var ExplainOptions = FindOptions{Sort: []string{"deviceId"}}
 
-	err := db.RunCommand(ctx, command).Decode(&result)
-	fmt.Println("RunExplainOnCollectionCluster")
-	if err != nil {
-		log.Fatalf("Failed to run explain: %v", err)
-	}
-	fmt.Printf("Explain result: %+v\n", result)
+type CollectionStats struct {
+	Count          int64
+	Size           int64
+	StorageSize    int64
+	TotalIndexSize int64
 }
@@ -0,0 +1,164 @@
+package queries
+
+import (
+	"context"
+	"test/config"
+	"test/stats"
+	"test/tracing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	PageKeyID       = config.PageKeyID
+	PageKeyDeviceID = config.PageKeyDeviceID
+)
+
+// ReadOptions shape a streaming read.
+type ReadOptions struct {
+	Filter Filter
+	// Sort is the order of a read that is not paged, as in FindOptions.
+	Sort []string
+	// BatchSize is how many documents the server returns per batch, 0
+	// leaves it to the server.
+	BatchSize int32
+	// PageSize reads in pages of this many documents, each a query of its
+	// own resuming after the last key of the previous page, in key order.
+	// 0 reads through one cursor.
+	PageSize int64
+	// PageKey is what pages are keyed on: PageKeyID, or PageKeyDeviceID
+	// with _id breaking ties.
+	PageKey string
+	// Limit stops the read after this many documents, 0 reads them all.
+	Limit int64
+	// ClusterKey is what the _id of a clustered collection is built from,
+	// for the layout to make its repository with.
+	ClusterKey []string
+}
+
+// ReadStats measure a streaming read.
+type ReadStats struct {
+	Documents int64
+	// Pages holds how long every page took, from sending its query to
+	// decoding its last document. A read that is not paged is one page.
+	Pages *stats.Histogram
+	// FirstDocument is how long the first document took to arrive.
+	FirstDocument time.Duration
+	Elapsed       time.Duration
+}
+
+// Read streams the documents opts selects, decoding them one at a time
+// without keeping them, so that it measures the server and the network
+// rather than how fast the client allocates.
+func (r AdvertisementHistoryRepo[T]) Read(ctx context.Context, opts ReadOptions) (read ReadStats, err error) {
+	read.Pages = stats.NewHistogram()
+	start := time.Now()
+	defer func() { read.Elapsed = time.Since(start) }()
+
+	// last is the last document of the previous page.
+	var last bson.Raw
+	for {
+		query := opts.Filter.document(r.key)
+		find := options.Find()
+		if opts.BatchSize > 0 {
+			find.SetBatchSize(opts.BatchSize)
+		}
+		limit := opts.Limit - read.Documents
+		if opts.Limit == 0 {
+			limit = 0
+		}
+		if opts.PageSize > 0 {
+			if last != nil {
+				query = bson.D{{Key: "$and", Value: bson.A{query, opts.after(last)}}}
+			}
+			find.SetSort(opts.keyOrder())
+			if limit == 0 || limit > opts.PageSize {
+				limit = opts.PageSize
+			}
+		} else if len(opts.Sort) > 0 {
+			find.SetSort(sortOrder(opts.Sort))
+		}
+		if limit > 0 {
+			find.SetLimit(limit)
+		}
+
+		pageStart := time.Now()
+		n, err := r.stream(ctx, query, find, func(doc bson.Raw) {
+			if read.Documents == 0 {
+				read.FirstDocument = time.Since(start)
+			}
+			read.Documents++
+			if opts.PageSize > 0 {
+				last = append(last[:0], doc...)
+			}
+		})
+		read.Pages.Since(pageStart)
+		if err != nil {
+			return read, err
+		}
+		if opts.PageSize == 0 || n < opts.PageSize || opts.Limit > 0 && read.Documents >= opts.Limit {
+			return read, nil
+		}
+	}
+}
+
+// stream runs query and decodes its documents one by one, calling each
+// with every raw document first. It returns how many there were.
+func (r AdvertisementHistoryRepo[T]) stream(ctx context.Context, query bson.D, find *options.FindOptions, each func(bson.Raw)) (int64, error) {
+	ctx, span := startSpan(ctx, "find", r.collection, query)
+	defer span.End()
+	cursor, err := r.collection.Find(ctx, query, find)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var n int64
+	for cursor.Next(ctx) {
+		each(cursor.Current)
+		var doc T
+		if err := cursor.Decode(&doc); err != nil {
+			tracing.RecordError(span, err)
+			return n, err
+		}
+		n++
+	}
+	setDocuments(span, int(n))
+	tracing.RecordError(span, cursor.Err())
+	return n, cursor.Err()
+}
+
+// CreatePageIndex builds the index that pages keyed on pageKey are read
+// through in key order, and returns its name. Only PageKeyDeviceID needs
+// one, for _id there is nothing to build and it returns "".
+func (r AdvertisementHistoryRepo[T]) CreatePageIndex(ctx context.Context, pageKey string) (string, error) {
+	if pageKey != PageKeyDeviceID {
+		return "", nil
+	}
+	return r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: ReadOptions{PageKey: pageKey}.keyOrder()})
+}
+
+func (o ReadOptions) keyOrder() bson.D {
+	if o.PageKey == PageKeyDeviceID {
+		return bson.D{{Key: "deviceId", Value: 1}, {Key: "_id", Value: 1}}
+	}
+	return bson.D{{Key: "_id", Value: 1}}
+}
+
+// after returns the condition selecting the documents after last in key
+// order.
+func (o ReadOptions) after(last bson.Raw) bson.D {
+	id := last.Lookup("_id")
+	if o.PageKey != PageKeyDeviceID {
+		return bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: id}}}}
+	}
+	deviceID := last.Lookup("deviceId")
+	return bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "deviceId", Value: bson.D{{Key: "$gt", Value: deviceID}}}},
+		bson.D{{Key: "deviceId", Value: deviceID}, {Key: "_id", Value: bson.D{{Key: "$gt", Value: id}}}},
+	}}}
+}
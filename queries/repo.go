@@ -0,0 +1,333 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"test/tracing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Filter selects AdvertisementHistory documents. Zero fields select
+// everything.
+type Filter struct {
+	DeviceID int64
+	ReqRefNo string
+	// From and To bound timeStamp, both inclusive.
+	From, To         int64
+	TMsgRecvByServer int64
+}
+
+// document returns f as a query on a collection clustered on key, which
+// adds the bounds of the _id to it.
+func (f Filter) document(key []string) bson.D {
+	filter := bson.D{}
+	if id := f.keyRange(key); id != nil {
+		filter = append(filter, bson.E{Key: "_id", Value: id})
+	}
+	if f.DeviceID != 0 {
+		filter = append(filter, bson.E{Key: "deviceId", Value: f.DeviceID})
+	}
+	if f.ReqRefNo != "" {
+		filter = append(filter, bson.E{Key: "reqRefNo", Value: f.ReqRefNo})
+	}
+	if f.From != 0 || f.To != 0 {
+		timeStamp := bson.D{}
+		if f.From != 0 {
+			timeStamp = append(timeStamp, bson.E{Key: "$gte", Value: f.From})
+		}
+		if f.To != 0 {
+			timeStamp = append(timeStamp, bson.E{Key: "$lte", Value: f.To})
+		}
+		filter = append(filter, bson.E{Key: "timeStamp", Value: timeStamp})
+	}
+	if f.TMsgRecvByServer != 0 {
+		filter = append(filter, bson.E{Key: "tMsgRecvByServer", Value: f.TMsgRecvByServer})
+	}
+	return filter
+}
+
+// FindOptions sort and limit what Find returns. Sort lists the fields to
+// sort by, descending when prefixed with "-". Limit 0 returns everything.
+type FindOptions struct {
+	Sort  []string
+	Limit int64
+}
+
+func (o FindOptions) options() *options.FindOptions {
+	opts := options.Find()
+	if len(o.Sort) > 0 {
+		opts.SetSort(sortOrder(o.Sort))
+	}
+	if o.Limit > 0 {
+		opts.SetLimit(o.Limit)
+	}
+	return opts
+}
+
+// sortOrder returns fields as a sort document, descending for the fields
+// prefixed with "-".
+func sortOrder(fields []string) bson.D {
+	sort := bson.D{}
+	for _, field := range fields {
+		if name, ok := strings.CutPrefix(field, "-"); ok {
+			sort = append(sort, bson.E{Key: name, Value: -1})
+		} else {
+			sort = append(sort, bson.E{Key: field, Value: 1})
+		}
+	}
+	return sort
+}
+
+// AdvertisementHistoryRepo reads and writes the AdvertisementHistory
+// documents of a collection, decoding them into T. Unlike the Mongo*
+// helpers it returns errors instead of exiting, so it can be used where a
+// failed operation is not the end of the program.
+type AdvertisementHistoryRepo[T any] struct {
+	collection *mongo.Collection
+	// key is the cluster key of the _ids, see WithClusterKey.
+	key []string
+}
+
+func NewAdvertisementHistoryRepo[T any](collection *mongo.Collection) AdvertisementHistoryRepo[T] {
+	return AdvertisementHistoryRepo[T]{collection: collection}
+}
+
+func (r AdvertisementHistoryRepo[T]) Collection() *mongo.Collection {
+	return r.collection
+}
+
+// WithClusterKey returns a repository of a collection whose _ids are
+// AdvertisementHistory.ClusterID(key). Its queries bound the _id to the
+// range of keys their filter selects, so that a clustered collection only
+// scans that range.
+func (r AdvertisementHistoryRepo[T]) WithClusterKey(key []string) AdvertisementHistoryRepo[T] {
+	r.key = key
+	return r
+}
+
+// Indexes are the secondary indexes CreateIndexes builds.
+var Indexes = []mongo.IndexModel{
+	{Keys: bson.D{{Key: "deviceId", Value: 1}, {Key: "audioPlayed", Value: 1}}},
+	{Keys: bson.D{{Key: "deviceId", Value: 1}, {Key: "tMsgRecvByServer", Value: 1}}},
+	{Keys: bson.D{{Key: "reqRefNo", Value: 1}}},
+	{Keys: bson.D{{Key: "tMsgRecvByServer", Value: 1}, {Key: "audioPlayed", Value: 1}}},
+}
+
+// CreateIndexes builds Indexes and returns their names.
+func (r AdvertisementHistoryRepo[T]) CreateIndexes(ctx context.Context) ([]string, error) {
+	return r.collection.Indexes().CreateMany(ctx, Indexes)
+}
+
+// Insert inserts document and returns its _id.
+func (r AdvertisementHistoryRepo[T]) Insert(ctx context.Context, document T) (interface{}, error) {
+	ctx, span := startSpan(ctx, "insert", r.collection, nil)
+	defer span.End()
+	setDocuments(span, 1)
+	result, err := r.collection.InsertOne(ctx, document)
+	tracing.RecordError(span, err)
+	if err != nil {
+		return nil, err
+	}
+	return result.InsertedID, nil
+}
+
+// InsertMany inserts documents in one call and returns how many of them
+// were inserted, which is less than all of them when it fails.
+func (r AdvertisementHistoryRepo[T]) InsertMany(ctx context.Context, documents []T, ordered bool) (int, error) {
+	ctx, span := startSpan(ctx, "insertMany", r.collection, nil)
+	defer span.End()
+	setDocuments(span, len(documents))
+	many := make([]interface{}, len(documents))
+	for i, document := range documents {
+		many[i] = document
+	}
+	_, err := r.collection.InsertMany(ctx, many, options.InsertMany().SetOrdered(ordered))
+	tracing.RecordError(span, err)
+	return inserted(len(documents), ordered, err), err
+}
+
+// inserted returns how many of n documents an InsertMany that returned err
+// inserted.
+func inserted(n int, ordered bool, err error) int {
+	var bulkErr mongo.BulkWriteException
+	switch {
+	case err == nil:
+		return n
+	case !errors.As(err, &bulkErr) || len(bulkErr.WriteErrors) == 0:
+		return 0
+	case ordered:
+		return bulkErr.WriteErrors[0].Index
+	default:
+		return n - len(bulkErr.WriteErrors)
+	}
+}
+
+// BulkWrite sends models in one call. The result counts what was written
+// even when it fails.
+func (r AdvertisementHistoryRepo[T]) BulkWrite(ctx context.Context, models []mongo.WriteModel, ordered bool) (*mongo.BulkWriteResult, error) {
+	ctx, span := startSpan(ctx, "bulkWrite", r.collection, nil)
+	defer span.End()
+	setDocuments(span, len(models))
+	result, err := r.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(ordered))
+	tracing.RecordError(span, err)
+	if result == nil {
+		result = &mongo.BulkWriteResult{}
+	}
+	return result, err
+}
+
+// Find returns the documents filter selects.
+func (r AdvertisementHistoryRepo[T]) Find(ctx context.Context, filter Filter, opts FindOptions) ([]T, error) {
+	query := filter.document(r.key)
+	ctx, span := startSpan(ctx, "find", r.collection, query)
+	defer span.End()
+	cursor, err := r.collection.Find(ctx, query, opts.options())
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var documents []T
+	err = cursor.All(ctx, &documents)
+	tracing.RecordError(span, err)
+	setDocuments(span, len(documents))
+	return documents, err
+}
+
+// Count returns how many documents filter selects.
+func (r AdvertisementHistoryRepo[T]) Count(ctx context.Context, filter Filter) (int64, error) {
+	query := filter.document(r.key)
+	ctx, span := startSpan(ctx, "count", r.collection, query)
+	defer span.End()
+	n, err := r.collection.CountDocuments(ctx, query)
+	tracing.RecordError(span, err)
+	return n, err
+}
+
+// Update sets the fields of set on the documents filter selects and
+// returns how many it matched and modified.
+func (r AdvertisementHistoryRepo[T]) Update(ctx context.Context, filter Filter, set bson.D) (matched, modified int64, err error) {
+	return r.updateMany(ctx, filter.document(r.key), set)
+}
+
+// UpdateFirst is Update limited to the first limit documents filter
+// selects, in natural order.
+func (r AdvertisementHistoryRepo[T]) UpdateFirst(ctx context.Context, filter Filter, limit int64, set bson.D) (matched, modified int64, err error) {
+	query := filter.document(r.key)
+	findCtx, span := startSpan(ctx, "find", r.collection, query)
+	cursor, err := r.collection.Find(findCtx, query, options.Find().SetLimit(limit).SetProjection(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		tracing.RecordError(span, err)
+		span.End()
+		return 0, 0, err
+	}
+	var ids []struct {
+		ID interface{} `bson:"_id"`
+	}
+	err = cursor.All(findCtx, &ids)
+	cursor.Close(findCtx)
+	tracing.RecordError(span, err)
+	setDocuments(span, len(ids))
+	span.End()
+	if err != nil || len(ids) == 0 {
+		return 0, 0, err
+	}
+
+	in := make(bson.A, len(ids))
+	for i, id := range ids {
+		in[i] = id.ID
+	}
+	return r.updateMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: in}}}}, set)
+}
+
+func (r AdvertisementHistoryRepo[T]) updateMany(ctx context.Context, query, set bson.D) (matched, modified int64, err error) {
+	ctx, span := startSpan(ctx, "updateMany", r.collection, query)
+	defer span.End()
+	result, err := r.collection.UpdateMany(ctx, query, bson.D{{Key: "$set", Value: set}})
+	if err != nil {
+		tracing.RecordError(span, err)
+		return 0, 0, err
+	}
+	setDocuments(span, int(result.ModifiedCount))
+	return result.MatchedCount, result.ModifiedCount, nil
+}
+
+// ListIndexes returns the indexes of the collection as listIndexes
+// describes them.
+func (r AdvertisementHistoryRepo[T]) ListIndexes(ctx context.Context) ([]bson.M, error) {
+	ctx, span := startSpan(ctx, "listIndexes", r.collection, nil)
+	defer span.End()
+	cursor, err := r.collection.Indexes().List(ctx)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	var indexes []bson.M
+	err = cursor.All(ctx, &indexes)
+	tracing.RecordError(span, err)
+	return indexes, err
+}
+
+// Explain returns the executionStats explain of Find(filter, opts).
+func (r AdvertisementHistoryRepo[T]) Explain(ctx context.Context, filter Filter, opts FindOptions) (bson.M, error) {
+	query := filter.document(r.key)
+	find := bson.D{{Key: "find", Value: r.collection.Name()}, {Key: "filter", Value: query}}
+	if len(opts.Sort) > 0 {
+		find = append(find, bson.E{Key: "sort", Value: sortOrder(opts.Sort)})
+	}
+	if opts.Limit > 0 {
+		find = append(find, bson.E{Key: "limit", Value: opts.Limit})
+	}
+	command := bson.D{{Key: "explain", Value: find}, {Key: "verbosity", Value: "executionStats"}}
+
+	ctx, span := startSpan(ctx, "explain", r.collection, query)
+	defer span.End()
+	var result bson.M
+	err := r.collection.Database().RunCommand(ctx, command).Decode(&result)
+	tracing.RecordError(span, err)
+	return result, err
+}
+
+// Stats returns the document count and on-disk sizes of the collection
+// from $collStats. Sizes are in bytes.
+func (r AdvertisementHistoryRepo[T]) Stats(ctx context.Context) (CollectionStats, error) {
+	pipeline := mongo.Pipeline{{{Key: "$collStats", Value: bson.D{{Key: "storageStats", Value: bson.D{}}}}}}
+	ctx, span := startSpan(ctx, "aggregate", r.collection, nil)
+	defer span.End()
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return CollectionStats{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var result struct {
+		StorageStats struct {
+			Count          float64 `bson:"count"`
+			Size           float64 `bson:"size"`
+			StorageSize    float64 `bson:"storageSize"`
+			TotalIndexSize float64 `bson:"totalIndexSize"`
+		} `bson:"storageStats"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&result); err != nil {
+			tracing.RecordError(span, err)
+			return CollectionStats{}, err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		tracing.RecordError(span, err)
+		return CollectionStats{}, err
+	}
+	return CollectionStats{
+		Count:          int64(result.StorageStats.Count),
+		Size:           int64(result.StorageStats.Size),
+		StorageSize:    int64(result.StorageStats.StorageSize),
+		TotalIndexSize: int64(result.StorageStats.TotalIndexSize),
+	}, nil
+}
@@ -0,0 +1,62 @@
+package queries
+
+import (
+	"context"
+	"strings"
+	"test/tracing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan starts the span of operation on collection. Only the shape of
+// filter is recorded, see FilterShape; a nil filter records none.
+func startSpan(ctx context.Context, operation string, collection *mongo.Collection, filter interface{}) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{
+		attribute.String("db.system", "mongodb"),
+		attribute.String("db.namespace", collection.Database().Name()),
+		attribute.String("db.collection.name", collection.Name()),
+		attribute.String("db.operation.name", operation),
+	}
+	if filter != nil {
+		attributes = append(attributes, attribute.String("db.query.shape", FilterShape(filter)))
+	}
+	return tracing.Start(ctx, operation+" "+collection.Name(), attributes...)
+}
+
+func setDocuments(span trace.Span, n int) {
+	span.SetAttributes(attribute.Int("mongobench.documents", n))
+}
+
+// FilterShape returns filter with every value replaced by the name of its
+// BSON type, such as {deviceId: 64-bit integer}, so operations can be
+// grouped by shape whatever the values.
+func FilterShape(filter interface{}) string {
+	data, err := bson.Marshal(filter)
+	if err != nil {
+		return "?"
+	}
+	var b strings.Builder
+	shape(&b, bson.Raw(data))
+	return b.String()
+}
+
+func shape(b *strings.Builder, doc bson.Raw) {
+	elements, _ := doc.Elements()
+	b.WriteByte('{')
+	for i, e := range elements {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(e.Key())
+		b.WriteString(": ")
+		if sub, ok := e.Value().DocumentOK(); ok {
+			shape(b, sub)
+		} else {
+			b.WriteString(e.Value().Type.String())
+		}
+	}
+	b.WriteByte('}')
+}
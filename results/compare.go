@@ -0,0 +1,32 @@
+package results
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// WriteComparison prints the results of the same workload run against
+// several layouts as one table, a row per layout and phase, so the
+// layouts can be compared phase by phase.
+func WriteComparison(out io.Writer, runs []*Result) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "layout\tphase\tops\tops/s\tdocs/s\tp50 ms\tp90 ms\tp99 ms\tp99.9 ms\tmax ms\terrors\tstorage MB\tindex MB\t")
+	for _, r := range runs {
+		var errors int64
+		for _, n := range r.Errors {
+			errors += n
+		}
+		for _, p := range r.Phases {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\t%.1f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%d\t%.1f\t%.1f\t\n",
+				r.Layout, p.Name, p.Operations, p.OpsPerSec, p.DocsPerSec,
+				p.Latency.P50, p.Latency.P90, p.Latency.P99, p.Latency.P999, p.Latency.Max,
+				errors, mb(r.StorageSize), mb(r.IndexSize))
+		}
+	}
+	return w.Flush()
+}
+
+func mb(bytes int64) float64 {
+	return float64(bytes) / (1 << 20)
+}
@@ -0,0 +1,427 @@
+package results
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"test/config"
+	"test/monitor"
+	"test/stats"
+	"time"
+)
+
+// Result is the structured outcome of one run, written next to the log
+// output so benchmark numbers can be loaded into spreadsheets without
+// scraping log lines.
+type Result struct {
+	// RunID tags the run's lines in the events log.
+	RunID      string `json:"runId,omitempty"`
+	Scenario   string `json:"scenario"`
+	Layout     string `json:"layout"`
+	Database   string `json:"database"`
+	Collection string `json:"collection"`
+	Workers    int    `json:"workers"`
+	// TargetOpsPerSec is the open-loop write rate, 0 for closed-loop runs.
+	TargetOpsPerSec float64          `json:"targetOpsPerSec,omitempty"`
+	StartedAt       time.Time        `json:"startedAt"`
+	FinishedAt      time.Time        `json:"finishedAt"`
+	Phases          []Phase          `json:"phases"`
+	Errors          map[string]int64 `json:"errors"`
+	// Documents, DataSize, StorageSize and IndexSize describe the collection
+	// once the run is over. Sizes are in bytes.
+	Documents   int64 `json:"documents"`
+	DataSize    int64 `json:"dataSize"`
+	StorageSize int64 `json:"storageSize"`
+	IndexSize   int64 `json:"indexSize"`
+	// Seed reproduces the generated documents when passed as data.seed.
+	Seed int64 `json:"seed"`
+	// ClusterKey is what the _id of clustered documents is made of, empty
+	// for ObjectIDs.
+	ClusterKey []string `json:"clusterKey,omitempty"`
+	// WriteServers and ReadServers count insert and find commands per
+	// replica set member, as seen by the command monitor.
+	WriteServers map[string]int64 `json:"writeServers"`
+	ReadServers  map[string]int64 `json:"readServers"`
+	Commands     []Command        `json:"commands"`
+	// Failures breaks the Errors down by member, error code and labels.
+	Failures []monitor.Failure `json:"failures"`
+	Pools    []Pool            `json:"pools"`
+	Failover *Failover         `json:"failover,omitempty"`
+	// PoolSamples is the state of every connection pool once a second.
+	PoolSamples []monitor.PoolSample `json:"poolSamples,omitempty"`
+	// ServerStatus is what serverStatus showed of every member at every
+	// sampling interval.
+	ServerStatus []ServerStatusSample `json:"serverStatus,omitempty"`
+	// Reads measures how the documents of every read phase arrived.
+	Reads []Read `json:"reads,omitempty"`
+}
+
+// Read is how a read phase streamed its documents. PeakHeapBytes is the
+// most heap the client had allocated while reading, HeapBeforeBytes what
+// it had allocated before.
+type Read struct {
+	Phase           string  `json:"phase"`
+	BatchSize       int32   `json:"batchSize"`
+	PageSize        int64   `json:"pageSize"`
+	PageKey         string  `json:"pageKey,omitempty"`
+	Documents       int64   `json:"documents"`
+	Pages           int64   `json:"pages"`
+	FirstDocumentMs float64 `json:"firstDocumentMs"`
+	DocsPerSec      float64 `json:"docsPerSec"`
+	HeapBeforeBytes uint64  `json:"heapBeforeBytes"`
+	PeakHeapBytes   uint64  `json:"peakHeapBytes"`
+}
+
+// ServerStatusSample is one member's serverStatus over one sampling
+// interval. The counters are deltas over the interval; Cache, Dirty and
+// the tickets are the values at its end. ClientOps is what the benchmark
+// itself sent to every member over the same interval.
+type ServerStatusSample struct {
+	Time    time.Time `json:"time"`
+	Host    string    `json:"host"`
+	Seconds float64   `json:"seconds"`
+
+	Inserts  int64 `json:"inserts"`
+	Queries  int64 `json:"queries"`
+	Updates  int64 `json:"updates"`
+	Deletes  int64 `json:"deletes"`
+	GetMores int64 `json:"getMores"`
+	Commands int64 `json:"commands"`
+
+	CacheBytes           int64 `json:"cacheBytes"`
+	CacheDirtyBytes      int64 `json:"cacheDirtyBytes"`
+	CacheMaxBytes        int64 `json:"cacheMaxBytes"`
+	CacheBytesRead       int64 `json:"cacheBytesRead"`
+	CacheBytesWritten    int64 `json:"cacheBytesWritten"`
+	PagesEvicted         int64 `json:"pagesEvicted"`
+	ApplicationEvictions int64 `json:"applicationEvictions"`
+
+	ReadTicketsOut        int64 `json:"readTicketsOut"`
+	ReadTicketsAvailable  int64 `json:"readTicketsAvailable"`
+	WriteTicketsOut       int64 `json:"writeTicketsOut"`
+	WriteTicketsAvailable int64 `json:"writeTicketsAvailable"`
+
+	BytesIn  int64 `json:"bytesIn"`
+	BytesOut int64 `json:"bytesOut"`
+	Requests int64 `json:"requests"`
+
+	DocsInserted int64 `json:"docsInserted"`
+	DocsReturned int64 `json:"docsReturned"`
+	DocsUpdated  int64 `json:"docsUpdated"`
+	DocsDeleted  int64 `json:"docsDeleted"`
+
+	ClientOps int64 `json:"clientOps"`
+}
+
+// Failover is what a failover run saw of a primary election. Times are
+// zero when the run never saw the event. The topology times come from the
+// driver's server description changes, the status times from polling
+// replSetGetStatus.
+type Failover struct {
+	PrimaryBefore       string    `json:"primaryBefore"`
+	PrimaryAfter        string    `json:"primaryAfter"`
+	PrimaryLostAt       time.Time `json:"primaryLostAt"`
+	NewPrimaryAt        time.Time `json:"newPrimaryAt"`
+	StatusPrimaryLostAt time.Time `json:"statusPrimaryLostAt"`
+	StatusNewPrimaryAt  time.Time `json:"statusNewPrimaryAt"`
+	ElectionSeconds     float64   `json:"electionSeconds"`
+	Writes              int64     `json:"writes"`
+	FailedWrites        int64     `json:"failedWrites"`
+	StalledWrites       int64     `json:"stalledWrites"`
+	RetriedWrites       int64     `json:"retriedWrites"`
+	FirstFailedWrite    time.Time `json:"firstFailedWrite"`
+	LastFailedWrite     time.Time `json:"lastFailedWrite"`
+	UnavailableSeconds  float64   `json:"unavailableSeconds"`
+}
+
+// Pool is the pool monitor's view of the connection pool to one member.
+type Pool struct {
+	Host           string  `json:"host"`
+	Created        int64   `json:"created"`
+	Closed         int64   `json:"closed"`
+	CheckedOut     int64   `json:"checkedOut"`
+	CheckoutFailed int64   `json:"checkoutFailed"`
+	Cleared        int64   `json:"cleared"`
+	CheckoutWait   Latency `json:"checkoutWaitMs"`
+}
+
+// Command is the command monitor's view of one command name.
+type Command struct {
+	Name      string  `json:"name"`
+	Succeeded int64   `json:"succeeded"`
+	Failed    int64   `json:"failed"`
+	Latency   Latency `json:"latencyMs"`
+	// Hosts counts the commands each member served.
+	Hosts map[string]int64 `json:"hosts"`
+}
+
+type Phase struct {
+	Name       string  `json:"name"`
+	Operations int64   `json:"operations"`
+	Documents  int64   `json:"documents"`
+	Seconds    float64 `json:"seconds"`
+	OpsPerSec  float64 `json:"opsPerSec"`
+	DocsPerSec float64 `json:"docsPerSec"`
+	Latency    Latency `json:"latencyMs"`
+}
+
+// Latency is a stats.Summary in milliseconds.
+type Latency struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	P999 float64 `json:"p999"`
+	Max  float64 `json:"max"`
+}
+
+// New builds the result of a run of cfg that started at startedAt and
+// recorded its operations in phases.
+func New(cfg config.Config, startedAt time.Time, phases *stats.Phases) *Result {
+	r := &Result{
+		Scenario:        cfg.Name,
+		Layout:          cfg.Layout,
+		Database:        cfg.Database,
+		Collection:      cfg.Collection,
+		Workers:         cfg.Workers,
+		ClusterKey:      cfg.ClusterKey,
+		TargetOpsPerSec: cfg.Rate.OpsPerSec,
+		StartedAt:       startedAt,
+		FinishedAt:      time.Now(),
+		Errors:          map[string]int64{},
+		WriteServers:    map[string]int64{},
+		ReadServers:     map[string]int64{},
+	}
+	for _, name := range phases.Names() {
+		summary := phases.Get(name).Summary()
+		elapsed := phases.Elapsed(name)
+		phase := Phase{
+			Name:       name,
+			Operations: summary.Count,
+			Documents:  phases.Docs(name),
+			Seconds:    elapsed.Seconds(),
+			Latency:    latency(summary),
+		}
+		if elapsed > 0 {
+			phase.OpsPerSec = float64(summary.Count) / elapsed.Seconds()
+			phase.DocsPerSec = float64(phase.Documents) / elapsed.Seconds()
+		}
+		r.Phases = append(r.Phases, phase)
+	}
+	return r
+}
+
+// AddMonitor copies what mon saw into the result: every command sent,
+// failed commands into Errors and Failures, the members that served
+// inserts and finds into WriteServers and ReadServers, and the connection
+// pools.
+func (r *Result) AddMonitor(mon *monitor.Monitor) {
+	for _, name := range monitor.Commands {
+		cmd := mon.Command(name)
+		if cmd.Succeeded+cmd.Failed == 0 {
+			continue
+		}
+		r.Commands = append(r.Commands, Command{
+			Name:      name,
+			Succeeded: cmd.Succeeded,
+			Failed:    cmd.Failed,
+			Latency:   latency(cmd.Latency.Summary()),
+			Hosts:     cmd.Hosts,
+		})
+		if cmd.Failed > 0 {
+			r.Errors[name] += cmd.Failed
+		}
+		for host, n := range cmd.Hosts {
+			switch name {
+			case "insert":
+				r.WriteServers[host] += n
+			case "find":
+				r.ReadServers[host] += n
+			}
+		}
+	}
+	r.Failures = mon.Failures()
+	for _, p := range mon.Pools() {
+		r.Pools = append(r.Pools, Pool{
+			Host:           p.Host,
+			Created:        p.Created,
+			Closed:         p.Closed,
+			CheckedOut:     p.CheckedOut,
+			CheckoutFailed: p.CheckoutFailed,
+			Cleared:        p.Cleared,
+			CheckoutWait:   latency(p.CheckoutWait.Summary()),
+		})
+	}
+	r.PoolSamples = mon.PoolSamples()
+}
+
+func latency(summary stats.Summary) Latency {
+	return Latency{
+		Min:  ms(summary.Min),
+		Mean: ms(summary.Mean),
+		P50:  ms(summary.P50),
+		P90:  ms(summary.P90),
+		P99:  ms(summary.P99),
+		P999: ms(summary.P999),
+		Max:  ms(summary.Max),
+	}
+}
+
+// Save writes the result as <dir>/<name>-<timestamp>.json and a matching
+// .csv with one row per phase, plus <name>-<timestamp>-pool.csv and
+// <name>-<timestamp>-serverstatus.csv with the pool and serverStatus
+// samples if there are any, and returns the JSON path.
+func (r *Result) Save(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	base := filepath.Join(dir, fmt.Sprintf("%s-%s", r.label(), r.StartedAt.Format("20060102-150405")))
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(base+".json", data, 0644); err != nil {
+		return "", err
+	}
+
+	if err := writeFile(base+".csv", r.WriteCSV); err != nil {
+		return "", err
+	}
+
+	if len(r.PoolSamples) > 0 {
+		if err := writeFile(base+"-pool.csv", r.WritePoolCSV); err != nil {
+			return "", err
+		}
+	}
+	if len(r.ServerStatus) > 0 {
+		if err := writeFile(base+"-serverstatus.csv", r.WriteServerStatusCSV); err != nil {
+			return "", err
+		}
+	}
+	return base + ".json", nil
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := write(file); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+var csvHeader = []string{
+	"scenario", "layout", "collection", "workers", "started_at", "seed", "phase",
+	"operations", "documents", "errors", "seconds", "ops_per_sec", "docs_per_sec",
+	"min_ms", "mean_ms", "p50_ms", "p90_ms", "p99_ms", "p999_ms", "max_ms",
+	"collection_documents", "storage_bytes", "index_bytes",
+}
+
+// WriteCSV writes one row per phase. Errors are per run, so every row
+// carries the run's total.
+func (r *Result) WriteCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(csvHeader); err != nil {
+		return err
+	}
+	var errors int64
+	for _, n := range r.Errors {
+		errors += n
+	}
+	for _, p := range r.Phases {
+		row := []string{
+			r.Scenario, r.Layout, r.Collection, strconv.Itoa(r.Workers), r.StartedAt.Format(time.RFC3339), strconv.FormatInt(r.Seed, 10), p.Name,
+			strconv.FormatInt(p.Operations, 10), strconv.FormatInt(p.Documents, 10), strconv.FormatInt(errors, 10),
+			num(p.Seconds), num(p.OpsPerSec), num(p.DocsPerSec),
+			num(p.Latency.Min), num(p.Latency.Mean), num(p.Latency.P50), num(p.Latency.P90),
+			num(p.Latency.P99), num(p.Latency.P999), num(p.Latency.Max),
+			strconv.FormatInt(r.Documents, 10), strconv.FormatInt(r.StorageSize, 10), strconv.FormatInt(r.IndexSize, 10),
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+var poolCSVHeader = []string{
+	"time", "host", "open", "in_use", "created", "closed", "checked_out", "checkout_failed", "cleared", "max_checkout_wait_ms",
+}
+
+// WritePoolCSV writes one row per pool sample.
+func (r *Result) WritePoolCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(poolCSVHeader); err != nil {
+		return err
+	}
+	for _, s := range r.PoolSamples {
+		row := []string{
+			s.Time.Format(time.RFC3339), s.Host, strconv.FormatInt(s.Open, 10), strconv.FormatInt(s.InUse, 10),
+			strconv.FormatInt(s.Created, 10), strconv.FormatInt(s.Closed, 10), strconv.FormatInt(s.CheckedOut, 10),
+			strconv.FormatInt(s.CheckoutFailed, 10), strconv.FormatInt(s.Cleared, 10), num(s.MaxWaitMs),
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+var serverStatusCSVHeader = []string{
+	"time", "host", "seconds", "inserts", "queries", "updates", "deletes", "getmores", "commands",
+	"cache_bytes", "cache_dirty_bytes", "cache_max_bytes", "cache_bytes_read", "cache_bytes_written", "pages_evicted", "application_evictions",
+	"read_tickets_out", "read_tickets_available", "write_tickets_out", "write_tickets_available",
+	"bytes_in", "bytes_out", "requests", "docs_inserted", "docs_returned", "docs_updated", "docs_deleted", "client_ops",
+}
+
+// WriteServerStatusCSV writes one row per serverStatus sample.
+func (r *Result) WriteServerStatusCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(serverStatusCSVHeader); err != nil {
+		return err
+	}
+	for _, s := range r.ServerStatus {
+		row := []string{s.Time.Format(time.RFC3339), s.Host, num(s.Seconds)}
+		for _, n := range []int64{
+			s.Inserts, s.Queries, s.Updates, s.Deletes, s.GetMores, s.Commands,
+			s.CacheBytes, s.CacheDirtyBytes, s.CacheMaxBytes, s.CacheBytesRead, s.CacheBytesWritten, s.PagesEvicted, s.ApplicationEvictions,
+			s.ReadTicketsOut, s.ReadTicketsAvailable, s.WriteTicketsOut, s.WriteTicketsAvailable,
+			s.BytesIn, s.BytesOut, s.Requests, s.DocsInserted, s.DocsReturned, s.DocsUpdated, s.DocsDeleted, s.ClientOps,
+		} {
+			row = append(row, strconv.FormatInt(n, 10))
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (r *Result) label() string {
+	for _, s := range []string{r.Scenario, r.Layout, r.Collection} {
+		if s != "" {
+			return s
+		}
+	}
+	return "run"
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func num(f float64) string {
+	return strconv.FormatFloat(f, 'f', 3, 64)
+}
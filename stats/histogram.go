@@ -0,0 +1,198 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// subBucketBits sets the precision of a Histogram: every power of two is
+// split into 1<<subBucketBits linear buckets, which keeps the reported
+// value of any percentile within 0.4% of the recorded one.
+const subBucketBits = 8
+const subBuckets = 1 << subBucketBits
+const bucketCount = (64 - subBucketBits) * subBuckets
+
+// Histogram is an HDR-style latency histogram. Values are kept in
+// log-linear buckets so memory stays constant no matter how many
+// operations are recorded, while percentiles keep a fixed relative
+// precision from nanoseconds up to minutes. It is safe for concurrent use.
+type Histogram struct {
+	mu     sync.Mutex
+	counts []int64
+	total  int64
+	sum    int64
+	min    int64
+	max    int64
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{counts: make([]int64, bucketCount), min: math.MaxInt64}
+}
+
+func (h *Histogram) Record(d time.Duration) {
+	v := int64(d)
+	if v < 0 {
+		v = 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[bucketOf(uint64(v))]++
+	h.total++
+	h.sum += v
+	if v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+}
+
+// Since records the time elapsed since start and returns it.
+func (h *Histogram) Since(start time.Time) time.Duration {
+	d := time.Since(start)
+	h.Record(d)
+	return d
+}
+
+// Merge adds every value recorded in other to h.
+func (h *Histogram) Merge(other *Histogram) {
+	if h == other {
+		return
+	}
+	other.mu.Lock()
+	counts := append([]int64(nil), other.counts...)
+	total, sum, min, max := other.total, other.sum, other.min, other.max
+	other.mu.Unlock()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, c := range counts {
+		h.counts[i] += c
+	}
+	h.total += total
+	h.sum += sum
+	if min < h.min {
+		h.min = min
+	}
+	if max > h.max {
+		h.max = max
+	}
+}
+
+// Sum returns the total of every recorded value.
+func (h *Histogram) Sum() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return time.Duration(h.sum)
+}
+
+func (h *Histogram) Count() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.total
+}
+
+// CountAtMost returns how many recorded values are at most d. Values that
+// share d's bucket count as well, so the answer is within the precision of
+// the histogram.
+func (h *Histogram) CountAtMost(d time.Duration) int64 {
+	v := int64(d)
+	if v < 0 {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var n int64
+	for _, c := range h.counts[:bucketOf(uint64(v))+1] {
+		n += c
+	}
+	return n
+}
+
+// Percentile returns the value below which q percent of the recorded values
+// fall, for q between 0 and 100.
+func (h *Histogram) Percentile(q float64) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.percentile(q)
+}
+
+func (h *Histogram) percentile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q / 100 * float64(h.total)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			v := highestEquivalent(i)
+			if v > h.max {
+				v = h.max
+			}
+			if v < h.min {
+				v = h.min
+			}
+			return time.Duration(v)
+		}
+	}
+	return time.Duration(h.max)
+}
+
+// Summary is a point-in-time view of a Histogram.
+type Summary struct {
+	Count int64         `json:"count"`
+	Min   time.Duration `json:"min"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	P999  time.Duration `json:"p999"`
+	Max   time.Duration `json:"max"`
+}
+
+func (h *Histogram) Summary() Summary {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.total == 0 {
+		return Summary{}
+	}
+	return Summary{
+		Count: h.total,
+		Min:   time.Duration(h.min),
+		Mean:  time.Duration(h.sum / h.total),
+		P50:   h.percentile(50),
+		P90:   h.percentile(90),
+		P99:   h.percentile(99),
+		P999:  h.percentile(99.9),
+		Max:   time.Duration(h.max),
+	}
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("count=%d min=%s p50=%s p90=%s p99=%s p99.9=%s max=%s mean=%s",
+		s.Count, s.Min, s.P50, s.P90, s.P99, s.P999, s.Max, s.Mean)
+}
+
+func bucketOf(v uint64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(v) - subBucketBits - 1
+	return (shift+1)*subBuckets + int(v>>uint(shift)) - subBuckets
+}
+
+func highestEquivalent(i int) int64 {
+	if i < subBuckets {
+		return int64(i)
+	}
+	shift := uint(i/subBuckets - 1)
+	lower := uint64(i%subBuckets+subBuckets) << shift
+	return int64(lower + (1 << shift) - 1)
+}
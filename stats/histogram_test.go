@@ -0,0 +1,142 @@
+package stats
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBucketOf(t *testing.T) {
+	tests := []struct {
+		value  uint64
+		bucket int
+	}{
+		{0, 0},
+		{1, 1},
+		{subBuckets - 1, subBuckets - 1},
+		{subBuckets, subBuckets},
+		{subBuckets + 1, subBuckets + 1},
+		{2*subBuckets - 1, 2*subBuckets - 1},
+		// From 2*subBuckets on, a bucket holds two values, then four...
+		{2 * subBuckets, 2 * subBuckets},
+		{2*subBuckets + 1, 2 * subBuckets},
+		{2*subBuckets + 2, 2*subBuckets + 1},
+		{4 * subBuckets, 3 * subBuckets},
+		{math.MaxInt64, bucketCount - 1},
+	}
+	for _, tt := range tests {
+		if got := bucketOf(tt.value); got != tt.bucket {
+			t.Errorf("bucketOf(%d) = %d, want %d", tt.value, got, tt.bucket)
+		}
+	}
+}
+
+func TestHighestEquivalent(t *testing.T) {
+	values := []uint64{0, 1, 255, 256, 511, 512, 513, 1000, 123456, 1e9, 3e10, 1 << 40, math.MaxInt64}
+	for _, v := range values {
+		b := bucketOf(v)
+		high := highestEquivalent(b)
+		if uint64(high) < v {
+			t.Errorf("highestEquivalent(bucketOf(%d)) = %d, below the value", v, high)
+		}
+		if bucketOf(uint64(high)) != b {
+			t.Errorf("highestEquivalent(%d) = %d is in bucket %d", b, high, bucketOf(uint64(high)))
+		}
+		if bucketOf(uint64(high)+1) == b && high != math.MaxInt64 {
+			t.Errorf("highestEquivalent(%d) = %d is not the highest value of the bucket", b, high)
+		}
+		if rel := float64(uint64(high)-v) / float64(v+1); rel > 1.0/subBuckets {
+			t.Errorf("value %d reported as %d, %.4f off", v, high, rel)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	tests := []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{50, 500 * time.Millisecond},
+		{90, 900 * time.Millisecond},
+		{99, 990 * time.Millisecond},
+		{99.9, 999 * time.Millisecond},
+		{100, 1000 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		got := h.Percentile(tt.q)
+		if got < tt.want || float64(got-tt.want) > float64(tt.want)/subBuckets {
+			t.Errorf("Percentile(%g) = %s, want %s within %.2f%%", tt.q, got, tt.want, 100.0/subBuckets)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []time.Duration
+		want   Summary
+	}{
+		{"empty", nil, Summary{}},
+		{"one", []time.Duration{3 * time.Millisecond}, Summary{
+			Count: 1, Min: 3 * time.Millisecond, Mean: 3 * time.Millisecond,
+			P50: 3 * time.Millisecond, P90: 3 * time.Millisecond, P99: 3 * time.Millisecond,
+			P999: 3 * time.Millisecond, Max: 3 * time.Millisecond,
+		}},
+		{"negative is zero", []time.Duration{-time.Second, 0}, Summary{Count: 2}},
+		{"small values are exact", []time.Duration{1, 2, 3, 4}, Summary{
+			Count: 4, Min: 1, Mean: 2, P50: 2, P90: 4, P99: 4, P999: 4, Max: 4,
+		}},
+	}
+	for _, tt := range tests {
+		h := NewHistogram()
+		for _, v := range tt.values {
+			h.Record(v)
+		}
+		if got := h.Summary(); got != tt.want {
+			t.Errorf("%s: Summary() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b := NewHistogram(), NewHistogram()
+	a.Record(time.Millisecond)
+	b.Record(time.Second)
+	b.Record(2 * time.Second)
+	a.Merge(b)
+	a.Merge(a)
+	s := a.Summary()
+	if s.Count != 3 || s.Min != time.Millisecond || s.Max != 2*time.Second {
+		t.Errorf("merged Summary() = %+v", s)
+	}
+	if sum := a.Sum(); sum != 3*time.Second+time.Millisecond {
+		t.Errorf("merged Sum() = %s", sum)
+	}
+}
+
+func TestCountAtMost(t *testing.T) {
+	h := NewHistogram()
+	for _, v := range []time.Duration{time.Millisecond, 2 * time.Millisecond, time.Second} {
+		h.Record(v)
+	}
+	tests := []struct {
+		d    time.Duration
+		want int64
+	}{
+		{-1, 0},
+		{0, 0},
+		{time.Millisecond, 1},
+		{500 * time.Millisecond, 2},
+		{time.Minute, 3},
+	}
+	for _, tt := range tests {
+		if got := h.CountAtMost(tt.d); got != tt.want {
+			t.Errorf("CountAtMost(%s) = %d, want %d", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package stats
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Phases keeps one Histogram per named phase of a run, such as "write" or
+// "read", and remembers the order the phases were first used in so reports
+// read in run order.
+type Phases struct {
+	mu      sync.Mutex
+	names   []string
+	hists   map[string]*Histogram
+	elapsed map[string]time.Duration
+	docs    map[string]int64
+}
+
+func NewPhases() *Phases {
+	return &Phases{
+		hists:   make(map[string]*Histogram),
+		elapsed: make(map[string]time.Duration),
+		docs:    make(map[string]int64),
+	}
+}
+
+// Get returns the histogram of phase, creating it on first use.
+func (p *Phases) Get(phase string) *Histogram {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h, ok := p.hists[phase]
+	if !ok {
+		h = NewHistogram()
+		p.hists[phase] = h
+		p.names = append(p.names, phase)
+	}
+	return h
+}
+
+// AddElapsed adds d to the wall clock time spent in phase. Concurrent
+// phases need it to report throughput, since their latencies overlap.
+func (p *Phases) AddElapsed(phase string, d time.Duration) {
+	p.Get(phase)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.elapsed[phase] += d
+}
+
+// Elapsed returns the wall clock time spent in phase. Phases that never
+// called AddElapsed ran one operation at a time, so the sum of their
+// latencies is used instead.
+func (p *Phases) Elapsed(phase string) time.Duration {
+	h := p.Get(phase)
+	p.mu.Lock()
+	d, ok := p.elapsed[phase]
+	p.mu.Unlock()
+	if ok {
+		return d
+	}
+	return h.Sum()
+}
+
+// AddDocs counts n documents written or read by phase. Phases whose
+// operations each touch one document do not need it.
+func (p *Phases) AddDocs(phase string, n int64) {
+	p.Get(phase)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.docs[phase] += n
+}
+
+// Docs returns the documents counted by AddDocs for phase, or its
+// operation count when AddDocs was never called.
+func (p *Phases) Docs(phase string) int64 {
+	h := p.Get(phase)
+	p.mu.Lock()
+	n, ok := p.docs[phase]
+	p.mu.Unlock()
+	if ok {
+		return n
+	}
+	return h.Count()
+}
+
+// Names returns the phases in the order they were first used.
+func (p *Phases) Names() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.names...)
+}
+
+// Log prints one latency line per phase, prefixed with the layout.
+func (p *Phases) Log(layout string) {
+	log.Printf("------ %s latency ------", layout)
+	for _, name := range p.Names() {
+		log.Printf("%-12s %s", name, p.Get(name).Summary())
+	}
+}
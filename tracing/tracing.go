@@ -0,0 +1,146 @@
+// Package tracing records a span per database operation and a child span
+// per command it sends with OpenTelemetry, and exports them to a file or to
+// a collector's OTLP/HTTP endpoint, so slow operations can be looked at in
+// a trace viewer.
+package tracing
+
+import (
+	"context"
+	"os"
+	"strings"
+	"test/config"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Tracer starts the spans of a run. A nil Tracer traces nothing.
+type Tracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+	// file is the file spans are written to, if any.
+	file *os.File
+}
+
+// New returns a tracer exporting to cfg.File or cfg.Endpoint that samples
+// cfg.Ratio of the root spans. attributes describe the run and are added to
+// every span as resource attributes.
+func New(ctx context.Context, cfg config.Tracing, attributes ...attribute.KeyValue) (*Tracer, error) {
+	t := &Tracer{}
+	var exporter sdktrace.SpanExporter
+	var err error
+	if cfg.File != "" {
+		t.file, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(t.file))
+	} else {
+		exporter, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(tracesURL(cfg.Endpoint)))
+	}
+	if err != nil {
+		if t.file != nil {
+			t.file.Close()
+		}
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(append(attributes, attribute.String("service.name", "mongobench"))...))
+	if err != nil {
+		return nil, err
+	}
+	// The batch span processor drops spans rather than block when the
+	// exporter falls behind, so a slow collector does not slow the run.
+	t.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Ratio))),
+	)
+	t.tracer = t.provider.Tracer("test/tracing")
+	return t, nil
+}
+
+// tracesURL returns the traces URL of a collector's OTLP/HTTP endpoint,
+// adding the /v1/traces path unless endpoint already ends with it.
+func tracesURL(endpoint string) string {
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	return url
+}
+
+// CommandMonitor returns a command monitor that records a span for every
+// command the driver sends, a child of the span of the operation that sent
+// it, and passes the events on to next.
+func (t *Tracer) CommandMonitor(next *event.CommandMonitor) *event.CommandMonitor {
+	if t == nil {
+		return next
+	}
+	commands := otelmongo.NewMonitor(otelmongo.WithTracerProvider(t.provider))
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			commands.Started(ctx, evt)
+			next.Started(ctx, evt)
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			commands.Succeeded(ctx, evt)
+			next.Succeeded(ctx, evt)
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			commands.Failed(ctx, evt)
+			next.Failed(ctx, evt)
+		},
+	}
+}
+
+// Close exports the spans that ended so far and closes the exporter.
+func (t *Tracer) Close(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	err := t.provider.Shutdown(ctx)
+	if t.file != nil {
+		if closeErr := t.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+type tracerKey struct{}
+
+// NewContext returns a context whose operations are traced by t.
+func NewContext(ctx context.Context, t *Tracer) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// Start starts a span named name, a child of the span of ctx if there is
+// one. The span does nothing when ctx has no tracer.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	t, _ := ctx.Value(tracerKey{}).(*Tracer)
+	if t == nil {
+		return ctx, noop.Span{}
+	}
+	return t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
+
+// RecordError marks span as failed with err, if err is not nil.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
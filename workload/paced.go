@@ -0,0 +1,114 @@
+package workload
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Schedule spaces out the operations of an open-loop run: the rate climbs
+// linearly from zero to Rate ops/sec over RampUp and then stays at Rate.
+type Schedule struct {
+	Rate   float64
+	RampUp time.Duration
+}
+
+// Offset returns when operation i is due, relative to the start of the run.
+func (s Schedule) Offset(i int) time.Duration {
+	ramp := s.RampUp.Seconds()
+	// During the ramp i(t) = Rate*t²/(2*ramp) operations are due by t.
+	rampOps := s.Rate * ramp / 2
+	var seconds float64
+	if float64(i) < rampOps {
+		seconds = math.Sqrt(2 * ramp * float64(i) / s.Rate)
+	} else {
+		seconds = ramp + (float64(i)-rampOps)/s.Rate
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// Paced calls op total times, each at the time sched says it is due, on
+// workers goroutines. Unlike Run it does not wait for a call to return
+// before the next one is due: when every worker is busy, due calls queue
+// up. op receives the time its call was due so latency can be measured from
+// there rather than from when a worker got round to it, which keeps server
+// stalls from hiding as a lower request rate. total and ctx bound the run
+// as they do for Run.
+//
+// The calls that were due but still queued when ctx ended are not made.
+// Paced returns when each of them was due and when ctx ended, so callers
+// can count them as having waited until then instead of losing them.
+func Paced(ctx context.Context, workers int, total int, sched Schedule, op func(ctx context.Context, worker int, i int, due time.Time)) (missed []time.Time, end time.Time) {
+	if workers < 1 {
+		workers = 1
+	}
+	type call struct {
+		i   int
+		due time.Time
+	}
+	opCtx := context.WithoutCancel(ctx)
+	calls := make(chan call, workers)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for c := range calls {
+				if ctx.Err() != nil {
+					mu.Lock()
+					missed = append(missed, c.due)
+					mu.Unlock()
+					continue
+				}
+				op(opCtx, worker, c.i, c.due)
+			}
+		}(w)
+	}
+
+	start := time.Now()
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	// blocked is the first call that was due but could not be queued
+	// before ctx ended, or -1.
+	blocked := -1
+schedule:
+	for i := 0; total <= 0 || i < total; i++ {
+		due := start.Add(sched.Offset(i))
+		if wait := time.Until(due); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				end = time.Now()
+				break schedule
+			}
+		}
+		select {
+		case calls <- call{i: i, due: due}:
+		case <-ctx.Done():
+			end, blocked = time.Now(), i
+			break schedule
+		}
+	}
+	close(calls)
+	wg.Wait()
+
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(end) {
+		end = deadline
+	}
+	if blocked >= 0 {
+		for i := blocked; total <= 0 || i < total; i++ {
+			due := start.Add(sched.Offset(i))
+			if !due.Before(end) {
+				break
+			}
+			missed = append(missed, due)
+		}
+	}
+	return missed, end
+}
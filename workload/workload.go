@@ -0,0 +1,77 @@
+package workload
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Run calls op total times spread over workers goroutines and returns once
+// every call has finished. With total 0 it keeps calling op until ctx is
+// done. Cancelling ctx stops new calls; calls already running finish with
+// a context that is not cancelled, so a time-bounded run does not fail
+// its last operations. Workers claim iterations from a shared counter, so
+// a slow worker does not hold back the others. op receives the index of
+// the worker calling it, which callers use to pick the worker's
+// collection and to keep per-worker tallies without locking, and the
+// index of the iteration, counting from 0.
+func Run(ctx context.Context, workers int, total int, op func(ctx context.Context, worker int, i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	opCtx := context.WithoutCancel(ctx)
+	var next int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				i := atomic.AddInt64(&next, 1) - 1
+				if total > 0 && i >= int64(total) {
+					return
+				}
+				op(opCtx, worker, int(i))
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// Collections returns the collection handle each worker should use. Without
+// affinity every worker shares client and its connection pool. With
+// affinity each worker gets its own client, built from clientOpts and
+// limited to a single connection, so a worker always talks over the same
+// socket. The returned function disconnects those extra clients.
+func Collections(ctx context.Context, client *mongo.Client, clientOpts *options.ClientOptions, database, collection string, workers int, affinity bool) ([]*mongo.Collection, func(), error) {
+	if workers < 1 {
+		workers = 1
+	}
+	collections := make([]*mongo.Collection, workers)
+	if !affinity {
+		for i := range collections {
+			collections[i] = client.Database(database).Collection(collection)
+		}
+		return collections, func() {}, nil
+	}
+
+	var clients []*mongo.Client
+	disconnect := func() {
+		for _, c := range clients {
+			c.Disconnect(context.TODO())
+		}
+	}
+	for i := range collections {
+		c, err := mongo.Connect(ctx, clientOpts, options.Client().SetMaxPoolSize(1))
+		if err != nil {
+			disconnect()
+			return nil, nil, err
+		}
+		clients = append(clients, c)
+		collections[i] = c.Database(database).Collection(collection)
+	}
+	return collections, disconnect, nil
+}